@@ -9,7 +9,11 @@
 package cache
 
 import (
+	"context"
+	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,6 +32,11 @@ type CacheI interface {
 	Name() string
 }
 
+var (
+	_ InterfaceCache[string, int] = (*Cache[string, int])(nil)
+	_ InterfaceCache[string, int] = (*ShardedCache[string, int])(nil)
+)
+
 // InterfaceCache 是cache的接口
 type InterfaceCache[K comparable, V any] interface {
 	Set(req K, values V)
@@ -37,7 +46,7 @@ type InterfaceCache[K comparable, V any] interface {
 	Size() int
 	Clear()
 	IsEmpty() bool
-	Range(fn func(s string, k K) bool)
+	Range(fn func(k K, v V) bool)
 	List() ([]K, []V)
 	ListKey() []K
 	ListValue() []V
@@ -54,7 +63,7 @@ func (c *Cache[K, V]) Remove(k ...K) {
 // Has 是否包含
 func (c *Cache[K, V]) Has(k ...K) bool {
 	for i := range k {
-		_, ok := c.Get(k[i])
+		ok := c.has(k[i])
 		if !ok {
 			return false
 		}
@@ -65,7 +74,7 @@ func (c *Cache[K, V]) Has(k ...K) bool {
 // HasAny 是否包含任何一个键
 func (c *Cache[K, V]) HasAny(k ...K) bool {
 	for i := range k {
-		_, ok := c.Get(k[i])
+		ok := c.has(k[i])
 		if ok {
 			return true
 		}
@@ -80,7 +89,14 @@ func (c *Cache[K, V]) Size() int {
 
 // Clear 清空cache
 func (c *Cache[K, V]) Clear() {
-	c.smap = &sync.Map{}
+	old := c.drain()
+	if len(c.onEvict) == 0 {
+		return
+	}
+	old.Range(func(k, v any) bool {
+		c.notifyEvict(removed[K]{k: k.(K), wp: v.(*wrap), reason: ReasonCleared})
+		return true
+	})
 }
 
 // IsEmpty 是否为空
@@ -113,6 +129,13 @@ func WithName[K comparable, V any](name string) Option[K, V] {
 	}
 }
 
+// WithNamespace 设置cache 的命名空间, cache 的名称是 命名空间/名称, 命名空间可以有多级比如 service/users
+func WithNamespace[K comparable, V any](ns string) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.namespace = ns
+	}
+}
+
 // WithNoManager 设置cache不受 cacheManager管理
 func WithNoManager[K comparable, V any]() Option[K, V] {
 	return func(cache *Cache[K, V]) {
@@ -120,17 +143,62 @@ func WithNoManager[K comparable, V any]() Option[K, V] {
 	}
 }
 
+// WithManager 设置cache 注册到的 CacheManager, 默认使用 CacheManagerFactory 返回的
+func WithManager[K comparable, V any](cm CacheManager) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.manager = cm
+	}
+}
+
+// WithCleanBudget 设置 Clean 每次最多删除的过期元素个数, 避免大cache 一次清理太久
+// 设置了 WithActiveExpiry 时 Len 仍然会删除所有到期的元素
+func WithCleanBudget[K comparable, V any](n int) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.cleanBudget = n
+	}
+}
+
+// WithCacheCleanInterval 设置 CacheManager 清理这个cache 的间隔, 默认每次执行tasks 都清理
+// 间隔会按 CacheManager 的 Interval 向上取整
+func WithCacheCleanInterval[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.cleanInterval = d
+	}
+}
+
+// CleanInterval 返回 CacheManager 清理这个cache 的间隔
+func (c *Cache[K, V]) CleanInterval() time.Duration {
+	return c.cleanInterval
+}
+
 // init 根据opts设置cache
 func (c *Cache[K, V]) init(opts ...Option[K, V]) {
 	c.ttl = defaultTTL
-	c.smap = &sync.Map{}
+	c.smap.Store(&sync.Map{})
+	c.nextExpiry.Store(math.MaxInt64)
+	c.tracer = NoopTracer{}
+	c.clock = RealClock
+	c.codec = GobCodec
 	c.name = uuid.NewString()
 	for i := range opts {
 		opts[i](c)
 	}
-	if c.ttl == defaultTTL {
+	if ns := strings.Trim(c.namespace, NamespaceSep); ns != `` {
+		c.name = ns + NamespaceSep + c.name
+	}
+	if c.ttl == defaultTTL && c.idleTimeout <= 0 && c.negativeTTL <= 0 {
 		c.noManager = true
 	}
+	if c.expiry != nil {
+		c.expiry.fire = func() { c.expireDue(0) }
+		c.expiry.clock = c.clock
+	}
+	if (c.maxEntries > 0 || c.maxCost > 0) && c.policy == nil {
+		c.policy = NewLRUPolicy[K]()
+	}
+	if ca, ok := c.policy.(capacityAware); ok {
+		ca.setCapacity(c.maxEntries)
+	}
 }
 
 // NewCache 创建新cache
@@ -138,11 +206,16 @@ func NewCache[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
 	res := Cache[K, V]{}
 	res.opts = opts
 	res.init(opts...)
+	if res.persistPath != `` {
+		res.startPersistence()
+	}
+	if res.walPath != `` {
+		res.startWriteLog()
+	}
 	if res.noManager {
 		return &res
 	}
-	cm := CacheManagerFactory()
-	cm.RegisterCache(&res)
+	res.register()
 	return &res
 }
 
@@ -162,11 +235,65 @@ func (c *Cache[K, V]) Options() []Option[K, V] {
 
 // Cache 是一个带超时的缓存, 超时的元素会获取不到并删除(默认情况下)
 type Cache[K comparable, V any] struct {
-	ttl       time.Duration
-	smap      *sync.Map
-	name      string
-	noManager bool
-	opts      []Option[K, V]
+	ttl         time.Duration
+	smap        atomic.Pointer[sync.Map] // 存储, TakeAll Clear 和 Close 会换成新的
+	swapMu      sync.RWMutex             // 修改存储时持有读锁, 换存储时持有写锁
+	name        string
+	noManager   bool
+	manager     CacheManager
+	registered  CacheManager // 注册到的 CacheManager
+	registerErr error
+	namespace   string
+
+	cleanBudget   int
+	cleanInterval time.Duration
+	weak          bool
+	closed        atomic.Bool
+	opts          []Option[K, V]
+
+	mu         sync.Mutex // 保护 policy, 设置了写日志时让修改和日志的顺序一致
+	maxEntries int
+	policy     EvictionPolicy[K]
+
+	weigher func(K, V) int64
+	maxCost int64
+	cost    atomic.Int64
+
+	loader      Loader[K, V]
+	loads       loadGroup[K, V]
+	negativeTTL time.Duration
+	negatives   sync.Map
+
+	refreshAhead time.Duration
+	staleGrace   time.Duration
+	refreshing   sync.Map
+
+	onEvict []func(K, V, Reason)
+	onSet   listeners[setEvent[K, V]]
+
+	stats  statsCounter
+	tracer Tracer
+
+	count      atomic.Int64 // 元素个数, 包括超时但还没删除的元素
+	nextExpiry atomic.Int64 // 设置了 WithActiveExpiry 时最早的超时时间 UnixNano, Len 用它判断是否需要先删除到期的元素
+
+	expiry *expiry[K]
+
+	idleTimeout  time.Duration
+	idleTouchHas bool
+
+	clock Clock
+
+	codec           Codec
+	persistPath     string
+	persistInterval time.Duration
+	persistMu       sync.Mutex // 保护 persistErr persistTimer
+	persistErr      error
+	persistTimer    Timer
+
+	walPath    string
+	walMaxSize int64
+	wal        *writeLog
 }
 
 // Name return name of cache
@@ -176,20 +303,176 @@ func (c *Cache[K, V]) Name() string {
 
 // Set 设置k，v
 func (c *Cache[K, V]) Set(req K, values V) {
-	if c == nil || c.smap == nil {
+	if c == nil || c.entries() == nil {
 		c = NewCache[K, V]()
 	}
-	c.smap.Store(req, c.wrapTTL(values))
+	c.set(req, values, c.wrapTTL(values))
+}
+
+// set 保存已经计算好超时时间的元素
+func (c *Cache[K, V]) set(req K, values V, wp *wrap) {
+	_, sp := c.startSpan(context.Background(), `cache.Set`, req)
+	defer sp.end()
+	c.stats.sets.Add(1)
+	c.prepare(req, values, wp)
+	if !c.locking() {
+		prev, ok := c.store(req, wp)
+		if ok {
+			c.notifyEvict(c.replaced(req, prev))
+		}
+		c.notifySet(req, values, prev)
+		return
+	}
+	c.mu.Lock()
+	prev, ok := c.store(req, wp)
+	var evicted []removed[K]
+	if c.policy != nil {
+		c.policy.Add(req)
+		evicted = c.evict(req)
+	}
+	c.logSet(req, values, wp)
+	c.logEvicted(evicted)
+	c.mu.Unlock()
+	if ok {
+		c.notifyEvict(c.replaced(req, prev))
+	}
+	c.notifyEvict(evicted...)
+	c.notifySet(req, values, prev)
+}
+
+// locking 写操作是否要持有 c.mu, 设置了淘汰策略或写日志时需要
+func (c *Cache[K, V]) locking() bool {
+	return c.policy != nil || c.wal != nil
 }
 
 // Del 根据key 删除 cache
 func (c *Cache[K, V]) Del(k K) {
-	c.smap.Delete(k)
+	locking := c.locking()
+	if locking {
+		c.mu.Lock()
+	}
+	prev, ok := c.remove(k)
+	if c.policy != nil {
+		c.policy.Remove(k)
+	}
+	if ok {
+		c.logDel(k)
+	}
+	if locking {
+		c.mu.Unlock()
+	}
+	if ok {
+		c.stats.deletes.Add(1)
+		c.notifyEvict(removed[K]{k: k, wp: prev, reason: ReasonExplicit})
+	}
+}
+
+// prepare 计算元素的cost 和空闲超时
+func (c *Cache[K, V]) prepare(k K, v V, wp *wrap) *wrap {
+	wp.cost = c.weigh(k, v)
+	if c.idleTimeout > 0 {
+		wp.hard = wp.timeout
+		wp.timeout = minTime(c.clock.Now().Add(c.idleTimeout), wp.hard)
+	}
+	return wp
+}
+
+// delExpired 删除过期的元素, 元素已经被更新时不删除, 返回是否删除了
+func (c *Cache[K, V]) delExpired(k K, wp *wrap) bool {
+	if c.policy != nil {
+		c.mu.Lock()
+	}
+	c.swapMu.RLock()
+	ok := c.entries().CompareAndDelete(k, wp)
+	if ok {
+		c.account(k, wp, nil)
+	}
+	c.swapMu.RUnlock()
+	if ok {
+		if c.policy != nil {
+			c.policy.Remove(k)
+		}
+	}
+	if c.policy != nil {
+		c.mu.Unlock()
+	}
+	if ok {
+		c.stats.expirations.Add(1)
+		c.notifyEvict(removed[K]{k: k, wp: wp, reason: ReasonExpired})
+	}
+	return ok
+}
+
+// replaced 返回被覆盖的元素, 已经过期的元素原因是 ReasonExpired
+func (c *Cache[K, V]) replaced(k K, prev *wrap) removed[K] {
+	r := removed[K]{k: k, wp: prev, reason: ReasonReplaced}
+	if _, ok := c.unWrapTTL(prev); !ok {
+		r.reason = ReasonExpired
+	}
+	return r
+}
+
+// entries 返回当前的存储, 只用于读取, 修改时需要持有 c.swapMu 的读锁
+func (c *Cache[K, V]) entries() *sync.Map {
+	return c.smap.Load()
+}
+
+// store 保存元素, 返回被覆盖的元素
+func (c *Cache[K, V]) store(k K, wp *wrap) (*wrap, bool) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+	prev, loaded := c.entries().Swap(k, wp)
+	if !loaded {
+		c.account(k, nil, wp)
+		return nil, false
+	}
+	c.account(k, prev.(*wrap), wp)
+	return prev.(*wrap), true
+}
+
+// remove 删除元素
+func (c *Cache[K, V]) remove(k K) (*wrap, bool) {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+	prev, loaded := c.entries().LoadAndDelete(k)
+	if !loaded {
+		return nil, false
+	}
+	wp := prev.(*wrap)
+	c.account(k, wp, nil)
+	return wp, true
+}
+
+// account 元素从prev 变成next 后更新计数、cost 和超时记录, nil 表示元素不存在
+func (c *Cache[K, V]) account(k K, prev, next *wrap) {
+	var delta int64
+	switch {
+	case prev == nil && next != nil:
+		c.count.Add(1)
+	case prev != nil && next == nil:
+		c.count.Add(-1)
+	}
+	if prev != nil {
+		delta -= prev.cost
+		if c.expiry != nil {
+			c.expiry.remove(prev)
+		}
+	}
+	if next != nil {
+		delta += next.cost
+		if c.expiry != nil {
+			c.observeExpiry(next.timeout)
+			c.expiry.push(k, next, next.timeout.Add(c.staleGrace))
+		}
+	}
+	if delta != 0 {
+		c.cost.Add(delta)
+	}
 }
 
 // Range 遍历cache
 func (c *Cache[K, V]) Range(fn func(k K, v V) bool) {
-	c.smap.Range(func(k, v any) bool {
+	c.entries().Range(func(k, v any) bool {
 		xv, ok := c.unWrapTTL(v)
 		if ok {
 			vk := k.(K)
@@ -224,49 +507,106 @@ func (c *Cache[K, V]) ListValue() []V {
 	return vs
 }
 
-// Clean 会被cache manager 定期调用删除过期的元素
+// Clean 会被cache manager 定期调用删除过期的元素和过期的加载错误
+// 设置了 WithCleanBudget 时每次最多删除budget 个元素, 剩下的留到下次
 func (c *Cache[K, V]) Clean() {
-	c.smap.Range(func(k, v any) bool {
-		if _, ok := c.unWrapTTL(v); !ok {
-			vk := k.(K)
-			c.Del(vk)
+	c.clean(c.cleanBudget)
+	c.cleanNegatives()
+}
+
+// clean 删除过期的元素, budget > 0 时最多删除budget 个
+func (c *Cache[K, V]) clean(budget int) {
+	if c.expiry != nil {
+		c.expireDue(budget)
+		return
+	}
+	n := 0
+	c.entries().Range(func(k, v any) bool {
+		wp := v.(*wrap)
+		if !c.expired(wp) {
+			return true
+		}
+		if budget > 0 && n >= budget {
+			return false
+		}
+		if c.delExpired(k.(K), wp) {
+			n++
 		}
 		return true
 	})
 }
 
+// observeExpiry 记录最早的超时时间
+func (c *Cache[K, V]) observeExpiry(t time.Time) {
+	n := t.UnixNano()
+	for {
+		cur := c.nextExpiry.Load()
+		if n >= cur || c.nextExpiry.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
 // Get 根据key获得value 超时或者空第二个返回值为false，否则返回true
 func (c *Cache[K, V]) Get(req K) (V, bool) {
-	zeroV := new(V)
-	if c == nil || c.smap == nil {
-		return *zeroV, false
+	if c == nil || c.entries() == nil {
+		return *new(V), false
 	}
-	wp, ok := c.smap.Load(req)
+	_, sp := c.startSpan(context.Background(), `cache.Get`, req)
+	v, ok := c.get(req, true)
+	if sp != nil {
+		sp.end(Attr(`cache.hit`, ok))
+	}
+	return v, ok
+}
+
+// get 根据key获得value, touch 为true 时延长空闲超时
+func (c *Cache[K, V]) get(req K, touch bool) (V, bool) {
+	zeroV := new(V)
+	wp, ok := c.entries().Load(req)
 	if !ok {
+		c.stats.misses.Add(1)
 		return *zeroV, false
 	}
 	v, ok := c.unWrapTTL(wp)
 	if !ok {
+		c.stats.misses.Add(1)
+		if c.expired(wp.(*wrap)) {
+			c.delExpired(req, wp.(*wrap))
+		}
 		return *zeroV, false
 	}
+	c.stats.hits.Add(1)
+	if c.policy != nil {
+		c.mu.Lock()
+		c.policy.Access(req)
+		c.mu.Unlock()
+	}
+	if touch && c.idleTimeout > 0 {
+		c.touch(req, wp.(*wrap))
+	}
+	c.maybeRefresh(req, wp.(*wrap))
 	vv := v.(V)
 	return vv, true
 }
 
-// Len 返回cache 长度
+// Len 返回cache 长度, 直接返回维护好的计数, 不会遍历cache
+// 设置了 WithActiveExpiry 时先删除已经到期的元素, 结果是准确的,
+// 否则可能包括已经超时但还没被 Get 或 Clean 删除的元素. 设置了 WithStaleWhileRevalidate 时包括宽限期内的元素
 func (c *Cache[K, V]) Len() int {
-	i := 0
-	c.Range(func(k K, v V) bool {
-		i++
-		return true
-	})
-	return i
+	if c.expiry != nil && c.clock.Now().UnixNano() >= c.nextExpiry.Load() {
+		c.expireDue(0)
+	}
+	return int(c.count.Load())
 }
 
 // wrap 是cache的元素
 type wrap struct {
 	timeout time.Time
 	v       any
+	cost    int64
+	hidx    int       // 在过期堆中的位置
+	hard    time.Time // 设置了空闲超时时的最长存活时间
 }
 
 // TimeValue 是一个带有时间的值
@@ -280,7 +620,22 @@ type TimeoutValue interface {
 }
 
 func (c *Cache[K, V]) wrapTTL(v any) *wrap {
+	return newWrap(v, c.ttl, c.clock.Now())
+}
+
+func (c *Cache[K, V]) unWrapTTL(v any) (any, bool) {
+	wp, ok := v.(*wrap)
+	if !ok {
+		return nil, false
+	}
+	if !wp.alive(c.clock.Now()) {
+		return nil, false
+	}
+	return wp.v, true
+}
 
+// newWrap 根据ttl 和值的类型计算超时时间
+func newWrap(v any, ttl time.Duration, now time.Time) *wrap {
 	switch tv := v.(type) {
 	case TimeoutValue:
 		return &wrap{
@@ -289,24 +644,18 @@ func (c *Cache[K, V]) wrapTTL(v any) *wrap {
 		}
 	case TimeValue:
 		return &wrap{
-			timeout: tv.Time().Add(c.ttl),
+			timeout: tv.Time().Add(ttl),
 			v:       v,
 		}
 	default:
 		return &wrap{
-			timeout: time.Now().Add(c.ttl),
+			timeout: now.Add(ttl),
 			v:       v,
 		}
 	}
 }
 
-func (c *Cache[K, V]) unWrapTTL(v any) (any, bool) {
-	wp, ok := v.(*wrap)
-	if !ok {
-		return nil, false
-	}
-	if time.Now().After(wp.timeout) {
-		return nil, false
-	}
-	return wp.v, true
+// alive 在now 时是否还没超时
+func (wp *wrap) alive(now time.Time) bool {
+	return !now.After(wp.timeout)
 }
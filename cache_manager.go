@@ -9,43 +9,130 @@
 package cache
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/weapons97/cache/wait"
 )
 
 var (
-	// defaultManager 默认的cachemanager
+	// defaultManager 默认的cachemanager, 第一次通过 CacheManagerFactory 获取时启动
 	defaultManager = newCacheManager()
+	defaultOnce    sync.Once
 )
 
 // CacheManager 持有所有的cache 可以定时执行cache 的tasks
 type CacheManager interface {
-	Tasks()                  // CacheManager 的tasks 会定期执行
-	RegisterCache(c CacheI)  // 注册cache
-	Interval() time.Duration // 返回执行tasks的间隔
+	Tasks()                            // CacheManager 的tasks 会定期执行
+	RegisterCache(c CacheI) error      // 注册cache, 名称重复时返回 ErrDuplicateName
+	UnregisterCache(name string)       // 注销cache
+	Lookup(name string) (CacheI, bool) // 根据名称查找cache
+	List() []string                    // 按顺序返回所有cache 的名称
+	ListNamespace(ns string) []string  // 按顺序返回命名空间ns 下所有cache 的名称
+	Interval() time.Duration           // 返回执行tasks的间隔
+	Range(fn func(c CacheI) bool)      // 遍历注册的cache
+	Start(ctx context.Context)         // 开始定期执行tasks, ctx 结束时停止
+	Stop()                             // 停止定期执行tasks
+
+	Schedule(name string, interval time.Duration, fn TaskFunc, opts ...TaskOption) error // 注册定期执行的任务
+	Unschedule(name string) bool                                                         // 取消任务
+	TaskStatus(name string) (TaskStatus, bool)                                           // 返回任务的状态
+	ListTasks() []TaskStatus                                                             // 返回所有任务的状态
+}
+
+// NamespaceSep 分隔cache 名称中的命名空间, 比如 service/users/byEmail
+const NamespaceSep = `/`
+
+// ErrDuplicateName 是注册的cache 名称已经被其他cache 使用时返回的错误
+var ErrDuplicateName = errors.New(`duplicate cache name`)
+
+// DuplicateNameError 是名称重复的错误, Registered 不为空时cache 以这个名称注册了
+type DuplicateNameError struct {
+	Name       string
+	Registered string
+}
+
+func (e *DuplicateNameError) Error() string {
+	if e.Registered == `` {
+		return fmt.Sprintf(`cache name %v already registered`, e.Name)
+	}
+	return fmt.Sprintf(`cache name %v already registered, registered as %v`, e.Name, e.Registered)
+}
+
+func (e *DuplicateNameError) Unwrap() error {
+	return ErrDuplicateName
 }
 
+// DuplicatePolicy 是注册重复名称时的处理方式
+type DuplicatePolicy int
+
+const (
+	// DuplicateSuffix 给名称加上 -2 -3 这样的后缀后注册, 是默认的处理方式
+	DuplicateSuffix DuplicatePolicy = iota
+	// DuplicateReject 拒绝注册
+	DuplicateReject
+)
+
 // RegisterCache 向CacheManager注册cache
 //func RegisterCache(c *Cache) {
 //	cm := CacheManagerFactory()
 //	cm.RegisterCache(c)
 //}
 
-func background() {
-	cm := CacheManagerFactory()
-	go wait.Forever(cm.Tasks, cm.Interval(), true)
+// ManagerOption 是 CacheManager 的选项
+type ManagerOption func(*cacheManager)
+
+// WithManagerClock 设置 CacheManager 定时执行tasks 使用的 Clock
+func WithManagerClock(clock Clock) ManagerOption {
+	return func(cm *cacheManager) {
+		cm.clock = clock
+	}
 }
 
-func newCacheManager() CacheManager {
-	cm := &cacheManager{caches: make(map[string]CacheI)}
+// WithCleanInterval 设置 CacheManager 执行tasks 的间隔
+func WithCleanInterval(d time.Duration) ManagerOption {
+	return func(cm *cacheManager) {
+		cm.cleanInterval = d
+	}
+}
+
+// WithCleanWorkers 设置并行清理cache 的goroutine 数, 默认是 GOMAXPROCS
+func WithCleanWorkers(n int) ManagerOption {
+	return func(cm *cacheManager) {
+		cm.workers = n
+	}
+}
+
+// WithDuplicatePolicy 设置注册重复名称时的处理方式
+func WithDuplicatePolicy(p DuplicatePolicy) ManagerOption {
+	return func(cm *cacheManager) {
+		cm.duplicate = p
+	}
+}
+
+func newCacheManager(opts ...ManagerOption) *cacheManager {
+	cm := &cacheManager{
+		caches:  make(map[string]CacheI),
+		cleaned: make(map[string]time.Time),
+		tasks:   make(map[string]*task),
+	}
 	cm.cleanInterval = time.Second * 60
+	cm.workers = runtime.GOMAXPROCS(0)
+	cm.clock = RealClock
+	for i := range opts {
+		opts[i](cm)
+	}
 	return cm
 }
 
-func init() {
-	background()
+// NewCacheManager 创建一个 CacheManager, 调用 Start 后按 Interval 用它的 Clock 定时执行tasks
+// cache 可以通过 WithManager 注册到它, 也可以替换 CacheManagerFactory 让新创建的cache 都注册到它
+func NewCacheManager(opts ...ManagerOption) CacheManager {
+	return newCacheManager(opts...)
 }
 
 // cacheManager 持有所有的cache 可以定时执行cache 的tasks
@@ -53,6 +140,80 @@ type cacheManager struct {
 	caches        map[string]CacheI
 	cachesl       sync.Mutex
 	cleanInterval time.Duration
+	clock         Clock
+	duplicate     DuplicatePolicy
+	workers       int
+	cleaned       map[string]time.Time // 每个cache 上次清理的时间
+
+	lifeMu  sync.Mutex // 保护 ctx cancel timer tasks
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timer   Timer
+	running sync.WaitGroup // 正在执行的tasks
+
+	tasks  map[string]*task
+	taskWG sync.WaitGroup // 正在运行的任务goroutine
+}
+
+// Start 开始定期执行tasks, 已经启动时什么也不做, ctx 结束或调用 Stop 后停止
+func (cm *cacheManager) Start(ctx context.Context) {
+	cm.lifeMu.Lock()
+	defer cm.lifeMu.Unlock()
+	if cm.ctx != nil && cm.ctx.Err() == nil {
+		return
+	}
+	cm.haltLocked()
+	cm.ctx, cm.cancel = context.WithCancel(ctx)
+	run := cm.ctx
+	cm.timer = cm.clock.AfterFunc(cm.cleanInterval, func() { cm.tick(run) })
+	for _, t := range cm.tasks {
+		cm.startTaskLocked(t)
+	}
+	go func() {
+		<-run.Done()
+		cm.lifeMu.Lock()
+		defer cm.lifeMu.Unlock()
+		if cm.ctx == run {
+			cm.haltLocked()
+		}
+	}()
+}
+
+// Stop 停止定期执行tasks 和 Schedule 的任务, 并等待正在执行的tasks 和任务结束, 不能在它们中调用
+func (cm *cacheManager) Stop() {
+	cm.lifeMu.Lock()
+	cm.haltLocked()
+	cm.lifeMu.Unlock()
+	cm.running.Wait()
+	cm.taskWG.Wait()
+}
+
+// haltLocked 停止当前的定时器, 调用方需持有 cm.lifeMu
+func (cm *cacheManager) haltLocked() {
+	if cm.ctx == nil {
+		return
+	}
+	cm.cancel()
+	cm.timer.Stop()
+	cm.ctx, cm.cancel, cm.timer = nil, nil, nil
+}
+
+// tick 执行tasks 后等待下一个间隔, run 已经停止时什么也不做
+func (cm *cacheManager) tick(run context.Context) {
+	cm.lifeMu.Lock()
+	if cm.ctx != run || run.Err() != nil {
+		cm.lifeMu.Unlock()
+		return
+	}
+	cm.running.Add(1)
+	cm.lifeMu.Unlock()
+	cm.Tasks()
+	cm.running.Done()
+	cm.lifeMu.Lock()
+	defer cm.lifeMu.Unlock()
+	if cm.ctx == run && run.Err() == nil {
+		cm.timer = cm.clock.AfterFunc(cm.cleanInterval, func() { cm.tick(run) })
+	}
 }
 
 // Interval 返回执行tasks的间隔
@@ -60,27 +221,171 @@ func (cm *cacheManager) Interval() time.Duration {
 	return cm.cleanInterval
 }
 
-// Tasks cacheManager 的tasks 会定期执行
+// Tasks cacheManager 的tasks 会定期执行, 目前是清理cache, 其他定期任务通过 Schedule 注册
 func (cm *cacheManager) Tasks() {
 	cm.clean()
 }
 
+// clean 清理到了清理间隔的cache, 由最多 workers 个goroutine 并行执行, 执行 Clean 时不持有锁
 func (cm *cacheManager) clean() {
+	now := cm.clock.Now()
+	cm.cachesl.Lock()
+	due := make([]CacheI, 0, len(cm.caches))
+	for name, c := range cm.caches {
+		c, ok := resolve(c)
+		if !ok {
+			delete(cm.caches, name)
+			delete(cm.cleaned, name)
+			continue
+		}
+		if ic, ok := c.(cleanIntervaler); ok && now.Sub(cm.cleaned[name]) < ic.CleanInterval() {
+			continue
+		}
+		cm.cleaned[name] = now
+		due = append(due, c)
+	}
+	cm.cachesl.Unlock()
+
+	workers := min(cm.workers, len(due))
+	if workers <= 1 {
+		for _, c := range due {
+			c.Clean()
+		}
+		return
+	}
+	ch := make(chan CacheI)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range ch {
+				c.Clean()
+			}
+		}()
+	}
+	for _, c := range due {
+		ch <- c
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// cleanIntervaler 是有自己清理间隔的cache
+type cleanIntervaler interface {
+	CleanInterval() time.Duration
+}
+
+// resolve 返回注册的cache, 弱引用的cache 已经被回收时第二个返回值为false
+func resolve(c CacheI) (CacheI, bool) {
+	w, ok := c.(weakRef)
+	if !ok {
+		return c, true
+	}
+	c = w.resolve()
+	return c, c != nil
+}
+
+// RegisterCache 注册cache, 同一个cache 可以重复注册
+// 名称已经被其他cache 使用时按 DuplicatePolicy 处理并返回 *DuplicateNameError
+func (cm *cacheManager) RegisterCache(c CacheI) error {
 	cm.cachesl.Lock()
 	defer cm.cachesl.Unlock()
-	for _, c := range cm.caches {
-		c.Clean()
+	name := c.Name()
+	if old, ok := cm.caches[name]; ok && !sameCache(old, c) {
+		if _, alive := resolve(old); alive {
+			if cm.duplicate == DuplicateReject {
+				return &DuplicateNameError{Name: name}
+			}
+			key := name
+			for i := 2; ok; i++ {
+				key = fmt.Sprintf(`%v-%v`, name, i)
+				_, ok = cm.caches[key]
+			}
+			cm.caches[key] = c
+			cm.cleaned[key] = cm.clock.Now()
+			return &DuplicateNameError{Name: name, Registered: key}
+		}
+	}
+	cm.caches[name] = c
+	cm.cleaned[name] = cm.clock.Now()
+	return nil
+}
+
+// sameCache 判断注册的old 是不是c
+func sameCache(old, c CacheI) bool {
+	if old == c {
+		return true
 	}
+	a, ok := resolve(old)
+	b, ok2 := resolve(c)
+	return ok && ok2 && a == b
 }
 
-// RegisterCache 注册cache
-func (cm *cacheManager) RegisterCache(c CacheI) {
+// Lookup 根据名称查找cache
+func (cm *cacheManager) Lookup(name string) (CacheI, bool) {
 	cm.cachesl.Lock()
 	defer cm.cachesl.Unlock()
-	cm.caches[c.Name()] = c
+	c, ok := cm.caches[name]
+	if !ok {
+		return nil, false
+	}
+	return resolve(c)
+}
+
+// List 按顺序返回所有cache 的名称
+func (cm *cacheManager) List() []string {
+	return cm.ListNamespace(``)
+}
+
+// ListNamespace 按顺序返回命名空间ns 和它的子命名空间下所有cache 的名称, ns 为空时返回全部
+func (cm *cacheManager) ListNamespace(ns string) []string {
+	prefix := strings.TrimSuffix(ns, NamespaceSep)
+	if prefix != `` {
+		prefix += NamespaceSep
+	}
+	cm.cachesl.Lock()
+	names := make([]string, 0, len(cm.caches))
+	for name, c := range cm.caches {
+		if _, ok := resolve(c); ok && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	cm.cachesl.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// UnregisterCache 注销cache, 不存在时什么也不做
+func (cm *cacheManager) UnregisterCache(name string) {
+	cm.cachesl.Lock()
+	defer cm.cachesl.Unlock()
+	delete(cm.caches, name)
+	delete(cm.cleaned, name)
+}
+
+// Range 遍历注册的cache, fn 返回false 时停止
+func (cm *cacheManager) Range(fn func(c CacheI) bool) {
+	cm.cachesl.Lock()
+	caches := make([]CacheI, 0, len(cm.caches))
+	for _, c := range cm.caches {
+		if c, ok := resolve(c); ok {
+			caches = append(caches, c)
+		}
+	}
+	cm.cachesl.Unlock()
+	for _, c := range caches {
+		if !fn(c) {
+			return
+		}
+	}
 }
 
 // CacheManagerFactory 返回 CacheManager 的方法
+// 默认返回全局的 CacheManager, 第一次调用时才启动它, 可以调用它的 Stop 停止
 var CacheManagerFactory = func() CacheManager {
+	defaultOnce.Do(func() {
+		defaultManager.Start(context.Background())
+	})
 	return defaultManager
 }
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterDuplicate(t *testing.T) {
+	cm := newCacheManager()
+	a := NewCache(WithTTL[string, int](time.Hour), WithManager[string, int](cm), WithName[string, int](`users`))
+	require.NoError(t, a.RegisterError())
+	require.NoError(t, cm.RegisterCache(a))
+
+	b := NewCache(WithTTL[string, int](time.Hour), WithManager[string, int](cm), WithName[string, int](`users`))
+	require.ErrorIs(t, b.RegisterError(), ErrDuplicateName)
+	require.Equal(t, `users-2`, b.Name())
+	c, ok := cm.Lookup(`users-2`)
+	require.True(t, ok)
+	require.Equal(t, CacheI(b), c)
+	c, _ = cm.Lookup(`users`)
+	require.Equal(t, CacheI(a), c)
+
+	require.NoError(t, b.Close())
+	require.Equal(t, []string{`users`}, cm.List())
+
+	sc := NewShardedCache(WithShardedTTL[string, int](time.Hour), WithShardedManager[string, int](cm), WithShardedName[string, int](`users`))
+	require.Equal(t, `users-2`, sc.Name())
+	require.NoError(t, sc.Close())
+	require.Equal(t, []string{`users`}, cm.List())
+
+	strict := newCacheManager(WithDuplicatePolicy(DuplicateReject))
+	require.NoError(t, strict.RegisterCache(a))
+	d := NewCache(WithNoManager[string, int](), WithName[string, int](`users`))
+	e := strict.RegisterCache(d)
+	dup := &DuplicateNameError{}
+	require.ErrorAs(t, e, &dup)
+	require.Empty(t, dup.Registered)
+	require.Equal(t, []string{`users`}, strict.List())
+}
+
+func TestNamespace(t *testing.T) {
+	cm := newCacheManager()
+	opts := func(ns, name string) []Option[string, int] {
+		return []Option[string, int]{
+			WithTTL[string, int](time.Hour),
+			WithManager[string, int](cm),
+			WithNamespace[string, int](ns),
+			WithName[string, int](name),
+		}
+	}
+	byEmail := NewCache(opts(`service/users`, `byEmail`)...)
+	NewCache(opts(`service/users/`, `byID`)...)
+	NewCache(opts(`service/orders`, `byID`)...)
+	NewCache(opts(`other`, `x`)...)
+	require.Equal(t, `service/users/byEmail`, byEmail.Name())
+	require.Equal(t, []string{`service/users/byEmail`, `service/users/byID`}, cm.ListNamespace(`service/users`))
+	require.Len(t, cm.ListNamespace(`service/`), 3)
+	require.Empty(t, cm.ListNamespace(`service/user`))
+	require.Len(t, cm.List(), 4)
+	c, ok := cm.Lookup(`service/users/byEmail`)
+	require.True(t, ok)
+	require.Equal(t, CacheI(byEmail), c)
+	_, ok = cm.Lookup(`service/users`)
+	require.False(t, ok)
+}
+
+// registeringCache 在 Clean 中注册新的cache, 清理时持有锁会死锁
+type registeringCache struct {
+	cm *cacheManager
+	n  int
+}
+
+func (rc *registeringCache) Clean() {
+	rc.n++
+	rc.cm.RegisterCache(NewCache(WithNoManager[string, int]()))
+}
+
+func (rc *registeringCache) Name() string {
+	return `registering`
+}
+
+func TestCleanWithoutLock(t *testing.T) {
+	cm := newCacheManager(WithCleanWorkers(4))
+	rc := &registeringCache{cm: cm}
+	cm.RegisterCache(rc)
+	cm.clean()
+	cm.clean()
+	require.Equal(t, 2, rc.n)
+	require.Len(t, cm.List(), 3)
+}
+
+func TestCleanBudget(t *testing.T) {
+	c := NewCache(WithNoManager[int, int](), WithCleanBudget[int, int](3))
+	for i := 0; i < 10; i++ {
+		c.SetWithTTL(i, i, time.Millisecond)
+	}
+	c.Set(10, 10)
+	time.Sleep(time.Millisecond * 5)
+	c.Clean()
+	require.Equal(t, int64(8), c.count.Load())
+	c.Clean()
+	require.Equal(t, int64(5), c.count.Load())
+	require.Equal(t, 5, c.Len())
+	c.Clean()
+	c.Clean()
+	require.Equal(t, 1, c.Len())
+}
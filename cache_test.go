@@ -123,3 +123,29 @@ func TestCacheHasAny(t *testing.T) {
 	// 测试空参数
 	require.False(t, c.HasAny())
 }
+
+func TestCacheLen(t *testing.T) {
+	c := NewCache(WithTTL[string, int](time.Millisecond * 50))
+	c.Set(`a`, 1)
+	c.Set(`a`, 2)
+	c.Set(`b`, 2)
+	require.Equal(t, 2, c.Len())
+	c.Del(`b`)
+	c.Del(`b`)
+	require.Equal(t, 1, c.Len())
+	time.Sleep(time.Millisecond * 60)
+	c.Set(`c`, 3)
+	// 没有 WithActiveExpiry 时超时的元素在 Clean 之后才不计入
+	require.Equal(t, 2, c.Len())
+	c.Clean()
+	require.Equal(t, 1, c.Len())
+	require.False(t, c.IsEmpty())
+	c.Clear()
+	require.True(t, c.IsEmpty())
+
+	a := NewCache(WithTTL[string, int](time.Millisecond*50), WithActiveExpiry[string, int]())
+	a.Set(`a`, 1)
+	a.SetWithTTL(`b`, 2, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	require.Equal(t, 1, a.Len())
+}
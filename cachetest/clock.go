@@ -0,0 +1,109 @@
+package cachetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weapons97/cache"
+)
+
+// FakeClock 是可以手动拨动的 cache.Clock, 定时器只在 Advance 时触发
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock 创建从now 开始的 FakeClock
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now 返回当前的假时间
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+// AfterFunc 创建在假时间d 之后执行f 的定时器
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) cache.Timer {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	t := &fakeTimer{fc: fc, fn: f}
+	t.resetLocked(d)
+	return t
+}
+
+// Advance 把时间拨快d, 按到期顺序在调用方的goroutine 中执行所有到期的定时器
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	target := fc.now.Add(d)
+	fc.mu.Unlock()
+	for {
+		fc.mu.Lock()
+		var next *fakeTimer
+		for _, t := range fc.timers {
+			if t.when.After(target) {
+				continue
+			}
+			if next == nil || t.when.Before(next.when) {
+				next = t
+			}
+		}
+		if next == nil {
+			fc.now = target
+			fc.mu.Unlock()
+			return
+		}
+		if next.when.After(fc.now) {
+			fc.now = next.when
+		}
+		fc.removeLocked(next)
+		fc.mu.Unlock()
+		next.fn()
+	}
+}
+
+// Timers 返回还没触发的定时器个数
+func (fc *FakeClock) Timers() int {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return len(fc.timers)
+}
+
+func (fc *FakeClock) removeLocked(t *fakeTimer) bool {
+	for i := range fc.timers {
+		if fc.timers[i] == t {
+			fc.timers = append(fc.timers[:i], fc.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer 是 FakeClock 的定时器
+type fakeTimer struct {
+	fc   *FakeClock
+	when time.Time
+	fn   func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fc.mu.Lock()
+	defer t.fc.mu.Unlock()
+	return t.fc.removeLocked(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.fc.mu.Lock()
+	defer t.fc.mu.Unlock()
+	return t.resetLocked(d)
+}
+
+func (t *fakeTimer) resetLocked(d time.Duration) bool {
+	active := t.fc.removeLocked(t)
+	t.when = t.fc.now.Add(d)
+	t.fc.timers = append(t.fc.timers, t)
+	return active
+}
@@ -0,0 +1,46 @@
+package cachetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weapons97/cache"
+)
+
+func TestFakeClockTTL(t *testing.T) {
+	fc := NewFakeClock(time.Now())
+	c := cache.NewCache(
+		cache.WithTTL[string, int](time.Second),
+		cache.WithClock[string, int](fc),
+		cache.WithNoManager[string, int](),
+	)
+	c.Set(`a`, 1)
+	fc.Advance(time.Millisecond * 999)
+	_, ok := c.Get(`a`)
+	require.True(t, ok)
+	fc.Advance(time.Millisecond * 2)
+	_, ok = c.Get(`a`)
+	require.False(t, ok)
+}
+
+func TestFakeClockActiveExpiry(t *testing.T) {
+	fc := NewFakeClock(time.Now())
+	expired := []string{}
+	c := cache.NewCache(
+		cache.WithTTL[string, int](time.Minute),
+		cache.WithClock[string, int](fc),
+		cache.WithActiveExpiry[string, int](),
+		cache.WithNoManager[string, int](),
+		cache.WithOnEvict(func(k string, v int, r cache.Reason) {
+			expired = append(expired, k)
+		}),
+	)
+	c.Set(`a`, 1)
+	fc.Advance(time.Second * 30)
+	c.Set(`b`, 2)
+	fc.Advance(time.Second * 31)
+	require.Equal(t, []string{`a`}, expired)
+	fc.Advance(time.Minute)
+	require.Equal(t, []string{`a`, `b`}, expired)
+}
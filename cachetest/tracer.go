@@ -0,0 +1,78 @@
+// Package cachetest 提供测试cache 时使用的工具
+package cachetest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/weapons97/cache"
+)
+
+// RecordedSpan 是 TraceRecorder 记录的span
+type RecordedSpan struct {
+	Name  string
+	Attrs map[string]any
+	Ended bool
+}
+
+// TraceRecorder 是记录所有span 的 cache.Tracer
+type TraceRecorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewTraceRecorder 创建 TraceRecorder
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{}
+}
+
+// Start 开始记录一个span
+func (r *TraceRecorder) Start(ctx context.Context, name string, attrs ...cache.Attribute) (context.Context, cache.Span) {
+	rs := &RecordedSpan{Name: name, Attrs: make(map[string]any)}
+	s := &recordingSpan{r: r, rs: rs}
+	s.SetAttributes(attrs...)
+	r.mu.Lock()
+	r.spans = append(r.spans, rs)
+	r.mu.Unlock()
+	return ctx, s
+}
+
+// Spans 返回已经结束的span
+func (r *TraceRecorder) Spans() []RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]RecordedSpan, 0, len(r.spans))
+	for _, rs := range r.spans {
+		if rs.Ended {
+			res = append(res, *rs)
+		}
+	}
+	return res
+}
+
+// Reset 清空记录
+func (r *TraceRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = nil
+}
+
+// recordingSpan 是 TraceRecorder 返回的 cache.Span
+type recordingSpan struct {
+	r  *TraceRecorder
+	rs *RecordedSpan
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...cache.Attribute) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	for _, a := range attrs {
+		s.rs.Attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordingSpan) End() {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.rs.Ended = true
+}
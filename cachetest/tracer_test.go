@@ -0,0 +1,41 @@
+package cachetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weapons97/cache"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	r := NewTraceRecorder()
+	c := cache.NewCache(
+		cache.WithName[string, int](`traced`),
+		cache.WithTracer[string, int](r),
+		cache.WithLoader(func(ctx context.Context, k string) (int, error) {
+			return 1, nil
+		}),
+	)
+	c.Set(`a`, 1)
+	c.Get(`a`)
+	c.Get(`b`)
+	spans := r.Spans()
+	require.Len(t, spans, 3)
+	require.Equal(t, `cache.Set`, spans[0].Name)
+	require.Equal(t, `cache.Get`, spans[1].Name)
+	require.Equal(t, `traced`, spans[1].Attrs[`cache.name`])
+	require.Equal(t, true, spans[1].Attrs[`cache.hit`])
+	require.Equal(t, false, spans[2].Attrs[`cache.hit`])
+	require.NotEqual(t, spans[1].Attrs[`cache.key_hash`], spans[2].Attrs[`cache.key_hash`])
+	require.Contains(t, spans[1].Attrs, `cache.duration`)
+
+	r.Reset()
+	_, e := c.GetOrLoad(context.Background(), `c`)
+	require.NoError(t, e)
+	names := []string{}
+	for _, s := range r.Spans() {
+		names = append(names, s.Name)
+	}
+	require.Contains(t, names, `cache.Load`)
+}
@@ -0,0 +1,46 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"time"
+)
+
+// Clock 提供当前时间和定时器, 测试时可以替换成假的时钟控制超时
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer 是 Clock 创建的定时器, *time.Timer 实现了这个接口
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock 使用系统时间
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// RealClock 是使用系统时间的 Clock, 是cache 和 CacheManager 默认的 Clock
+var RealClock Clock = realClock{}
+
+// WithClock 设置cache 的 Clock, Indexer 的主表也可以使用这个选项
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.clock = clock
+	}
+}
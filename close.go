@@ -0,0 +1,189 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"weak"
+)
+
+// WithWeakRegistration 只用弱引用把cache 注册到 CacheManager
+// 不再被使用的cache 可以被回收, CacheManager 清理时自动注销它
+// 设置了 WithActiveExpiry 或 WithPersistence 的cache 在定时器触发前仍然可达
+func WithWeakRegistration[K comparable, V any]() Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.weak = true
+	}
+}
+
+// weakRef 是用弱引用注册的cache, cache 被回收后 resolve 返回nil
+type weakRef interface {
+	resolve() CacheI
+}
+
+// weakCache 是 Cache 的弱引用
+type weakCache[K comparable, V any] struct {
+	name string
+	p    weak.Pointer[Cache[K, V]]
+}
+
+func (w *weakCache[K, V]) resolve() CacheI {
+	if c := w.p.Value(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (w *weakCache[K, V]) Clean() {
+	if c := w.p.Value(); c != nil {
+		c.Clean()
+	}
+}
+
+func (w *weakCache[K, V]) Name() string {
+	return w.name
+}
+
+// register 把cache 注册到 CacheManager, 名称重复并被加上后缀时cache 使用新的名称
+func (c *Cache[K, V]) register() {
+	var ci CacheI = c
+	if c.weak {
+		ci = &weakCache[K, V]{name: c.name, p: weak.Make(c)}
+	}
+	c.name, c.registered, c.registerErr = registerTo(c.manager, c.name, ci)
+}
+
+// registerTo 把ci 注册到cm, cm 为nil 时使用 CacheManagerFactory
+// 返回注册后的名称和管理它的 CacheManager, 没有注册时 CacheManager 为nil
+func registerTo(cm CacheManager, name string, ci CacheI) (string, CacheManager, error) {
+	if cm == nil {
+		cm = CacheManagerFactory()
+	}
+	err := cm.RegisterCache(ci)
+	dup := &DuplicateNameError{}
+	switch {
+	case err == nil:
+		return name, cm, nil
+	case errors.As(err, &dup) && dup.Registered != ``:
+		return dup.Registered, cm, err
+	}
+	return name, nil, err
+}
+
+// RegisterError 返回注册到 CacheManager 时的错误
+// 名称重复时cache 按 DuplicatePolicy 改名注册或者不受 CacheManager 管理
+func (c *Cache[K, V]) RegisterError() error {
+	return c.registerErr
+}
+
+// Close 从 CacheManager 注销cache, 停止定时器并释放所有元素, 不会触发 WithOnEvict
+// 设置了 WithPersistence 时先写入一次快照, 设置了 WithWriteLog 时关闭日志文件
+// 可以多次调用, Close 之后cache 仍然可以使用, 但不再受 CacheManager 管理
+func (c *Cache[K, V]) Close() error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	if c.registered != nil {
+		c.registered.UnregisterCache(c.name)
+	}
+	var err error
+	if c.persistPath != `` {
+		c.stopPersistence()
+		err = c.Save()
+	}
+	if c.wal != nil {
+		if e := c.wal.close(); err == nil {
+			err = e
+		}
+	}
+	c.reset()
+	c.negatives.Clear()
+	return err
+}
+
+// Close 从 CacheManager 注销set 并释放所有元素
+func (s *Set[K]) Close() error {
+	return s.inner.Close()
+}
+
+// Close 关闭主表和所有索引表, 并关闭所有watcher 的channel
+func (ix *Indexer[T]) Close() error {
+	var err error
+	if ix.main != nil {
+		err = ix.main.Close()
+	}
+	ix.rw.Lock()
+	cs := ix.cs
+	ix.cs = make(map[string]*Cache[string, *Set[string]])
+	ix.rw.Unlock()
+	for _, c := range cs {
+		c.Range(func(_ string, set *Set[string]) bool {
+			set.Close()
+			return true
+		})
+		c.Close()
+	}
+	ix.watchersMu.Lock()
+	ws := ix.watchers
+	ix.watchers = nil
+	ix.watchersMu.Unlock()
+	for w := range ws {
+		w.close()
+	}
+	return err
+}
+
+// Close 从 CacheManager 注销cache 并释放所有元素
+func (sc *ShardedCache[K, V]) Close() error {
+	if sc.registered != nil {
+		sc.registered.UnregisterCache(sc.name)
+		sc.registered = nil
+	}
+	sc.Clear()
+	return nil
+}
+
+// reset 换成空的存储并清空计数、过期记录和淘汰策略, 返回原来的存储
+func (c *Cache[K, V]) reset() *sync.Map {
+	if c.locking() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	return c.resetLocked()
+}
+
+// drain 和 reset 相同, 同时在写日志中记录清空
+func (c *Cache[K, V]) drain() *sync.Map {
+	if c.locking() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	old := c.resetLocked()
+	c.logClear()
+	return old
+}
+
+// resetLocked 和 reset 相同, 调用方需要在 locking 时持有 c.mu
+func (c *Cache[K, V]) resetLocked() *sync.Map {
+	c.swapMu.Lock()
+	defer c.swapMu.Unlock()
+	old := c.smap.Swap(&sync.Map{})
+	c.count.Store(0)
+	c.cost.Store(0)
+	c.nextExpiry.Store(math.MaxInt64)
+	if c.expiry != nil {
+		c.expiry.reset()
+	}
+	if c.policy != nil {
+		c.policy.Reset()
+	}
+	return old
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func registered(cm *cacheManager) []string {
+	names := []string{}
+	cm.Range(func(c CacheI) bool {
+		names = append(names, c.Name())
+		return true
+	})
+	return names
+}
+
+func TestCacheClose(t *testing.T) {
+	cm := newCacheManager()
+	c := NewCache(WithTTL[string, int](time.Hour), WithManager[string, int](cm), WithName[string, int](`c`))
+	c.Set(`a`, 1)
+	require.Equal(t, []string{`c`}, registered(cm))
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Close())
+	require.Empty(t, registered(cm))
+	require.True(t, c.IsEmpty())
+
+	s := NewSet(WithTTL[string, struct{}](time.Hour), WithManager[string, struct{}](cm))
+	s.Add(`a`)
+	require.Len(t, registered(cm), 1)
+	require.NoError(t, s.Close())
+	require.Empty(t, registered(cm))
+	require.Equal(t, 0, s.Size())
+
+	sc := NewShardedCache(WithShardedTTL[string, int](time.Hour), WithShardedManager[string, int](cm))
+	sc.Set(`a`, 1)
+	require.Len(t, registered(cm), 1)
+	require.NoError(t, sc.Close())
+	require.Empty(t, registered(cm))
+}
+
+func TestCloseSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.snap`)
+	c := NewCache(WithPersistence[string, int](path, time.Hour))
+	c.Set(`a`, 1)
+	require.NoError(t, c.Close())
+	r := NewCache(WithPersistence[string, int](path, 0))
+	require.True(t, r.Has(`a`))
+}
+
+func TestIndexerClose(t *testing.T) {
+	ix := NewIndexer[*Person]()
+	ch := ix.Watch(context.Background())
+	ix.Set(p1)
+	<-ch
+	require.NoError(t, ix.Close())
+	_, ok := <-ch
+	require.False(t, ok)
+	require.Equal(t, 0, ix.Len())
+	require.True(t, ix.Search(IndexByCountry, `China`).Failed())
+}
+
+func TestWeakRegistration(t *testing.T) {
+	cm := newCacheManager()
+	func() {
+		c := NewCache(
+			WithTTL[string, int](time.Hour),
+			WithManager[string, int](cm),
+			WithWeakRegistration[string, int](),
+			WithName[string, int](`weak`),
+		)
+		c.Set(`a`, 1)
+		require.Equal(t, []string{`weak`}, registered(cm))
+	}()
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		cm.clean()
+		cm.cachesl.Lock()
+		defer cm.cachesl.Unlock()
+		return len(cm.caches) == 0
+	}, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,65 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+// Codec 是快照的编码方式, 一个快照由多次 Encode 的结果依次组成
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder 把值写入流
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder 从流中读出值, 流结束时返回 io.EOF
+type Decoder interface {
+	Decode(v any) error
+}
+
+var (
+	// GobCodec 使用 encoding/gob, 是默认的 Codec, 值是接口类型时需要先 gob.Register
+	GobCodec Codec = gobCodec{}
+	// JSONCodec 使用 encoding/json
+	JSONCodec Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// WithCodec 设置快照使用的 Codec, 默认是 GobCodec
+func WithCodec[K comparable, V any](codec Codec) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.codec = codec
+	}
+}
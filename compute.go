@@ -0,0 +1,192 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+// Action 是 Compute 函数返回的操作
+type Action int
+
+const (
+	// ActionSet 保存新值
+	ActionSet Action = iota
+	// ActionDelete 删除元素
+	ActionDelete
+	// ActionNoop 不修改cache
+	ActionNoop
+)
+
+// current 返回key 当前的元素, 已经超时的元素alive 为false
+func (c *Cache[K, V]) current(k K) (wp *wrap, alive bool) {
+	x, ok := c.entries().Load(k)
+	if !ok {
+		return nil, false
+	}
+	wp = x.(*wrap)
+	return wp, wp.alive(c.clock.Now())
+}
+
+// entry 用cache 的ttl 创建新元素
+func (c *Cache[K, V]) entry(k K, v V) *wrap {
+	return c.prepare(k, v, c.wrapTTL(v))
+}
+
+// mutate 在key 的元素仍然是old 时把它换成nw, old 为nil 表示元素不存在, nw 为nil 表示删除
+// 成功时和 Set、Del 一样更新统计并调用回调
+func (c *Cache[K, V]) mutate(k K, old, nw *wrap) bool {
+	locking := c.locking()
+	if locking {
+		c.mu.Lock()
+	}
+	ok := false
+	c.swapMu.RLock()
+	m := c.entries()
+	switch {
+	case old == nil && nw != nil:
+		_, loaded := m.LoadOrStore(k, nw)
+		ok = !loaded
+	case nw != nil:
+		ok = m.CompareAndSwap(k, old, nw)
+	case old != nil:
+		ok = m.CompareAndDelete(k, old)
+	}
+	if ok {
+		c.account(k, old, nw)
+	}
+	c.swapMu.RUnlock()
+	var evicted []removed[K]
+	if ok {
+		if c.policy != nil && nw != nil {
+			c.policy.Add(k)
+			evicted = c.evict(k)
+		} else if c.policy != nil {
+			c.policy.Remove(k)
+		}
+		if nw != nil {
+			c.logSet(k, nw.v.(V), nw)
+		} else {
+			c.logDel(k)
+		}
+		c.logEvicted(evicted)
+	}
+	if locking {
+		c.mu.Unlock()
+	}
+	if !ok {
+		return false
+	}
+	switch {
+	case old != nil && nw != nil:
+		c.notifyEvict(c.replaced(k, old))
+	case old != nil:
+		c.stats.deletes.Add(1)
+		c.notifyEvict(removed[K]{k: k, wp: old, reason: ReasonExplicit})
+	}
+	c.notifyEvict(evicted...)
+	if nw != nil {
+		c.stats.sets.Add(1)
+		c.notifySet(k, nw.v.(V), old)
+	}
+	return true
+}
+
+// GetOrSet 返回key 已有的值, 不存在时保存v, loaded 为true 表示返回的是已有的值
+func (c *Cache[K, V]) GetOrSet(k K, v V) (actual V, loaded bool) {
+	for {
+		wp, alive := c.current(k)
+		if alive {
+			return wp.v.(V), true
+		}
+		if c.mutate(k, wp, c.entry(k, v)) {
+			return v, false
+		}
+	}
+}
+
+// SetIfAbsent key 不存在时保存v, 返回是否保存了
+func (c *Cache[K, V]) SetIfAbsent(k K, v V) bool {
+	_, loaded := c.GetOrSet(k, v)
+	return !loaded
+}
+
+// Replace key 存在时用v 替换并重新计算超时, 返回是否替换了
+func (c *Cache[K, V]) Replace(k K, v V) bool {
+	for {
+		wp, alive := c.current(k)
+		if !alive {
+			return false
+		}
+		if c.mutate(k, wp, c.entry(k, v)) {
+			return true
+		}
+	}
+}
+
+// CompareAndSwap key 的值等于old 时换成new 并重新计算超时
+// 和 sync.Map 一样, 值的类型不可比较时会panic
+func (c *Cache[K, V]) CompareAndSwap(k K, old, new V) bool {
+	for {
+		wp, alive := c.current(k)
+		if !alive || wp.v != any(old) {
+			return false
+		}
+		if c.mutate(k, wp, c.entry(k, new)) {
+			return true
+		}
+	}
+}
+
+// CompareAndDelete key 的值等于old 时删除
+// 和 sync.Map 一样, 值的类型不可比较时会panic
+func (c *Cache[K, V]) CompareAndDelete(k K, old V) bool {
+	for {
+		wp, alive := c.current(k)
+		if !alive || wp.v != any(old) {
+			return false
+		}
+		if c.mutate(k, wp, nil) {
+			return true
+		}
+	}
+}
+
+// Compute 根据key 当前的值原子的计算新值, fn 的ok 表示key 是否存在
+// fn 返回 ActionSet 保存新值, ActionDelete 删除, ActionNoop 不修改
+// 并发修改同一个key 时fn 可能被调用多次, fn 不应该有副作用
+// 返回key 最终的值和是否存在
+func (c *Cache[K, V]) Compute(k K, fn func(old V, ok bool) (V, Action)) (V, bool) {
+	for {
+		wp, alive := c.current(k)
+		var old V
+		if alive {
+			old = wp.v.(V)
+		}
+		v, action := fn(old, alive)
+		switch action {
+		case ActionSet:
+			if c.mutate(k, wp, c.entry(k, v)) {
+				return v, true
+			}
+		case ActionDelete:
+			if wp == nil || c.mutate(k, wp, nil) {
+				return *new(V), false
+			}
+		default:
+			return old, alive
+		}
+	}
+}
+
+// Update key 存在时用fn 计算新值并保存, 返回新值和key 是否存在
+func (c *Cache[K, V]) Update(k K, fn func(old V) V) (V, bool) {
+	return c.Compute(k, func(old V, ok bool) (V, Action) {
+		if !ok {
+			return old, ActionNoop
+		}
+		return fn(old), ActionSet
+	})
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrSet(t *testing.T) {
+	c := NewCache[string, int]()
+	v, loaded := c.GetOrSet(`a`, 1)
+	require.False(t, loaded)
+	require.Equal(t, 1, v)
+	v, loaded = c.GetOrSet(`a`, 2)
+	require.True(t, loaded)
+	require.Equal(t, 1, v)
+	require.False(t, c.SetIfAbsent(`a`, 3))
+	require.True(t, c.SetIfAbsent(`b`, 3))
+	require.Equal(t, 2, c.Len())
+}
+
+func TestGetOrSetExpired(t *testing.T) {
+	c := NewCache(WithTTL[string, int](time.Millisecond * 20))
+	c.Set(`a`, 1)
+	time.Sleep(time.Millisecond * 30)
+	v, loaded := c.GetOrSet(`a`, 2)
+	require.False(t, loaded)
+	require.Equal(t, 2, v)
+	require.Equal(t, 1, c.Len())
+}
+
+func TestReplaceCompare(t *testing.T) {
+	c := NewCache[string, int]()
+	require.False(t, c.Replace(`a`, 1))
+	c.Set(`a`, 1)
+	require.True(t, c.Replace(`a`, 2))
+	require.False(t, c.CompareAndSwap(`a`, 1, 3))
+	require.True(t, c.CompareAndSwap(`a`, 2, 3))
+	require.False(t, c.CompareAndDelete(`a`, 2))
+	require.True(t, c.CompareAndDelete(`a`, 3))
+	require.True(t, c.IsEmpty())
+}
+
+func TestCompute(t *testing.T) {
+	c := NewCache[string, int]()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Compute(`n`, func(old int, ok bool) (int, Action) {
+				return old + 1, ActionSet
+			})
+		}()
+	}
+	wg.Wait()
+	v, ok := c.Get(`n`)
+	require.True(t, ok)
+	require.Equal(t, 100, v)
+
+	v, ok = c.Compute(`n`, func(old int, ok bool) (int, Action) {
+		return 0, ActionDelete
+	})
+	require.False(t, ok)
+	require.False(t, c.Has(`n`))
+
+	_, ok = c.Update(`n`, func(old int) int { return old + 1 })
+	require.False(t, ok)
+	c.Set(`n`, 1)
+	v, ok = c.Update(`n`, func(old int) int { return old + 1 })
+	require.True(t, ok)
+	require.Equal(t, 2, v)
+}
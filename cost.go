@@ -0,0 +1,45 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+// Sized 是一个知道自己大小的值, 大小作为cache 元素的cost
+type Sized interface {
+	Size() int64
+}
+
+// WithWeigher 设置计算元素cost 的函数, 优先于 Sized
+func WithWeigher[K comparable, V any](weigher func(K, V) int64) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.weigher = weigher
+	}
+}
+
+// WithMaxCost 设置cache 的cost 预算(比如字节数), 超过后按淘汰策略删除, 默认使用LRU
+// cost 超过预算的元素写入后立即被淘汰, 不会挤掉其他元素
+func WithMaxCost[K comparable, V any](budget int64) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.maxCost = budget
+	}
+}
+
+// Cost 返回cache 当前的总cost
+func (c *Cache[K, V]) Cost() int64 {
+	return c.cost.Load()
+}
+
+// weigh 计算元素的cost, 没有 weigher 也没实现 Sized 的值按1计
+func (c *Cache[K, V]) weigh(k K, v V) int64 {
+	if c.weigher != nil {
+		return c.weigher(k, v)
+	}
+	if sv, ok := any(v).(Sized); ok {
+		return sv.Size()
+	}
+	return 1
+}
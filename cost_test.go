@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sizedVal int64
+
+func (s sizedVal) Size() int64 {
+	return int64(s)
+}
+
+func TestCostWeigher(t *testing.T) {
+	c := NewCache(
+		WithMaxCost[string, string](10),
+		WithWeigher(func(k string, v string) int64 { return int64(len(v)) }),
+	)
+	c.Set(`a`, `1234`)
+	c.Set(`b`, `1234`)
+	require.Equal(t, int64(8), c.Cost())
+	c.Set(`c`, `1234`)
+	require.False(t, c.Has(`a`))
+	require.True(t, c.Has(`b`, `c`))
+	require.Equal(t, int64(8), c.Cost())
+	c.Set(`b`, `12`)
+	require.Equal(t, int64(6), c.Cost())
+	c.Del(`c`)
+	require.Equal(t, int64(2), c.Cost())
+	c.Clear()
+	require.Equal(t, int64(0), c.Cost())
+}
+
+func TestCostSized(t *testing.T) {
+	c := NewCache(WithMaxCost[string, sizedVal](100))
+	c.Set(`a`, 60)
+	c.Set(`b`, 30)
+	require.Equal(t, int64(90), c.Cost())
+	c.Set(`c`, 50)
+	require.Equal(t, int64(80), c.Cost())
+	require.True(t, c.Has(`b`, `c`))
+}
+
+func TestCostOversized(t *testing.T) {
+	reasons := []Reason{}
+	c := NewCache(
+		WithMaxCost[string, string](10),
+		WithWeigher(func(k string, v string) int64 { return int64(len(v)) }),
+		WithOnEvict(func(k string, v string, r Reason) {
+			reasons = append(reasons, r)
+		}),
+	)
+	c.Set(`a`, `1234`)
+	c.Set(`b`, `1234`)
+	c.Set(`big`, `1234567890123456`)
+	require.False(t, c.Has(`big`))
+	require.True(t, c.Has(`a`, `b`))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, int64(8), c.Cost())
+	require.Equal(t, []Reason{ReasonCapacity}, reasons)
+
+	c.Set(`a`, `12345678901`)
+	require.False(t, c.Has(`a`))
+	require.Equal(t, int64(4), c.Cost())
+}
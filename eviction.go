@@ -0,0 +1,387 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// EvictionPolicy 淘汰策略, cache 超过容量时由它选出被淘汰的key
+// EvictionPolicy 的方法都在cache 的锁内调用, 实现不需要自己加锁
+type EvictionPolicy[K comparable] interface {
+	Add(k K)          // 写入key, 已存在的key 视为一次访问
+	Access(k K)       // 访问key, 不存在的key 忽略
+	Remove(k K)       // 删除key
+	Evict() (K, bool) // 选出并移除一个被淘汰的key
+	Len() int         // 当前记录的key 数量
+	Reset()           // 清空
+}
+
+// capacityAware 需要知道cache 容量的淘汰策略(比如ARC) 实现这个接口
+type capacityAware interface {
+	setCapacity(n int)
+}
+
+// WithMaxEntries 设置cache 最大元素个数, 超过后按淘汰策略删除, 默认使用LRU
+func WithMaxEntries[K comparable, V any](n int) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.maxEntries = n
+	}
+}
+
+// WithEvictionPolicy 设置cache 的淘汰策略
+func WithEvictionPolicy[K comparable, V any](p EvictionPolicy[K]) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.policy = p
+	}
+}
+
+// lruPolicy 最近最少使用
+type lruPolicy[K comparable] struct {
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+// NewLRUPolicy 创建LRU 淘汰策略
+func NewLRUPolicy[K comparable]() EvictionPolicy[K] {
+	return &lruPolicy[K]{ll: list.New(), items: make(map[K]*list.Element)}
+}
+
+func (p *lruPolicy[K]) Add(k K) {
+	if e, ok := p.items[k]; ok {
+		p.ll.MoveToFront(e)
+		return
+	}
+	p.items[k] = p.ll.PushFront(k)
+}
+
+func (p *lruPolicy[K]) Access(k K) {
+	if e, ok := p.items[k]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy[K]) Remove(k K) {
+	if e, ok := p.items[k]; ok {
+		p.ll.Remove(e)
+		delete(p.items, k)
+	}
+}
+
+func (p *lruPolicy[K]) Evict() (K, bool) {
+	e := p.ll.Back()
+	if e == nil {
+		return *new(K), false
+	}
+	k := p.ll.Remove(e).(K)
+	delete(p.items, k)
+	return k, true
+}
+
+func (p *lruPolicy[K]) Len() int {
+	return p.ll.Len()
+}
+
+func (p *lruPolicy[K]) Reset() {
+	p.ll.Init()
+	p.items = make(map[K]*list.Element)
+}
+
+// fifoPolicy 先进先出, 访问不影响淘汰顺序
+type fifoPolicy[K comparable] struct {
+	lruPolicy[K]
+}
+
+// NewFIFOPolicy 创建FIFO 淘汰策略
+func NewFIFOPolicy[K comparable]() EvictionPolicy[K] {
+	return &fifoPolicy[K]{lruPolicy[K]{ll: list.New(), items: make(map[K]*list.Element)}}
+}
+
+func (p *fifoPolicy[K]) Add(k K) {
+	if _, ok := p.items[k]; ok {
+		return
+	}
+	p.items[k] = p.ll.PushFront(k)
+}
+
+func (p *fifoPolicy[K]) Access(k K) {}
+
+// lfuEntry 是lfuPolicy 堆中的元素
+type lfuEntry[K comparable] struct {
+	k     K
+	freq  int
+	seq   uint64
+	index int
+}
+
+// lfuHeap 按访问次数排序, 次数相同时先淘汰较早访问的
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) Len() int { return len(h) }
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x any) {
+	e := x.(*lfuEntry[K])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// lfuPolicy 最不经常使用
+type lfuPolicy[K comparable] struct {
+	h     lfuHeap[K]
+	items map[K]*lfuEntry[K]
+	seq   uint64
+}
+
+// NewLFUPolicy 创建LFU 淘汰策略
+func NewLFUPolicy[K comparable]() EvictionPolicy[K] {
+	return &lfuPolicy[K]{items: make(map[K]*lfuEntry[K])}
+}
+
+func (p *lfuPolicy[K]) Add(k K) {
+	if _, ok := p.items[k]; ok {
+		p.Access(k)
+		return
+	}
+	p.seq++
+	e := &lfuEntry[K]{k: k, freq: 1, seq: p.seq}
+	heap.Push(&p.h, e)
+	p.items[k] = e
+}
+
+func (p *lfuPolicy[K]) Access(k K) {
+	e, ok := p.items[k]
+	if !ok {
+		return
+	}
+	p.seq++
+	e.freq++
+	e.seq = p.seq
+	heap.Fix(&p.h, e.index)
+}
+
+func (p *lfuPolicy[K]) Remove(k K) {
+	e, ok := p.items[k]
+	if !ok {
+		return
+	}
+	heap.Remove(&p.h, e.index)
+	delete(p.items, k)
+}
+
+func (p *lfuPolicy[K]) Evict() (K, bool) {
+	if len(p.h) == 0 {
+		return *new(K), false
+	}
+	e := heap.Pop(&p.h).(*lfuEntry[K])
+	delete(p.items, e.k)
+	return e.k, true
+}
+
+func (p *lfuPolicy[K]) Len() int {
+	return len(p.h)
+}
+
+func (p *lfuPolicy[K]) Reset() {
+	p.h = nil
+	p.items = make(map[K]*lfuEntry[K])
+}
+
+// arcPolicy 自适应替换缓存(Adaptive Replacement Cache)
+// t1 是只访问过一次的key, t2 是访问过多次的key, b1 b2 是它们被淘汰后的影子记录
+// p 是t1 的目标大小, 会根据影子记录的命中情况自适应调整
+type arcPolicy[K comparable] struct {
+	capacity int
+	p        int
+	hitB2    bool
+	t1, t2   *list.List
+	b1, b2   *list.List
+	items    map[K]*arcEntry
+}
+
+// arcEntry 记录key 所在的链表
+type arcEntry struct {
+	e  *list.Element
+	in *list.List
+}
+
+// NewARCPolicy 创建ARC 淘汰策略, 容量取自 WithMaxEntries
+// 只设置了 WithMaxCost 时用当前的元素个数作为容量, 影子记录不会无限增长
+func NewARCPolicy[K comparable]() EvictionPolicy[K] {
+	p := &arcPolicy[K]{}
+	p.Reset()
+	return p
+}
+
+func (p *arcPolicy[K]) setCapacity(n int) {
+	p.capacity = n
+}
+
+// limit 返回容量, 没有设置容量时是当前的元素个数
+func (p *arcPolicy[K]) limit() int {
+	if p.capacity > 0 {
+		return p.capacity
+	}
+	return max(p.Len(), 1)
+}
+
+// trim 删除超过容量的影子记录
+func (p *arcPolicy[K]) trim() {
+	c := p.limit()
+	for p.b1.Len() > 0 && p.t1.Len()+p.b1.Len() > c {
+		p.drop(p.b1)
+	}
+	for p.b2.Len() > 0 && p.Len()+p.b1.Len()+p.b2.Len() > 2*c {
+		p.drop(p.b2)
+	}
+}
+
+func (p *arcPolicy[K]) move(k K, to *list.List) {
+	if ae, ok := p.items[k]; ok {
+		ae.in.Remove(ae.e)
+	}
+	p.items[k] = &arcEntry{e: to.PushFront(k), in: to}
+}
+
+func (p *arcPolicy[K]) drop(l *list.List) {
+	e := l.Back()
+	if e == nil {
+		return
+	}
+	delete(p.items, l.Remove(e).(K))
+}
+
+func (p *arcPolicy[K]) Add(k K) {
+	p.hitB2 = false
+	ae, ok := p.items[k]
+	switch {
+	case ok && (ae.in == p.t1 || ae.in == p.t2):
+		p.move(k, p.t2)
+		return
+	case ok && ae.in == p.b1:
+		p.p = min(p.limit(), p.p+max(p.b2.Len()/p.b1.Len(), 1))
+		p.move(k, p.t2)
+		return
+	case ok && ae.in == p.b2:
+		p.p = max(0, p.p-max(p.b1.Len()/p.b2.Len(), 1))
+		p.hitB2 = true
+		p.move(k, p.t2)
+		return
+	}
+	p.move(k, p.t1)
+	p.trim()
+}
+
+func (p *arcPolicy[K]) Access(k K) {
+	ae, ok := p.items[k]
+	if ok && (ae.in == p.t1 || ae.in == p.t2) {
+		p.move(k, p.t2)
+	}
+}
+
+func (p *arcPolicy[K]) Remove(k K) {
+	ae, ok := p.items[k]
+	if ok && (ae.in == p.t1 || ae.in == p.t2) {
+		ae.in.Remove(ae.e)
+		delete(p.items, k)
+	}
+}
+
+func (p *arcPolicy[K]) Evict() (K, bool) {
+	from, ghost := p.t2, p.b2
+	if p.t1.Len() > 0 && (p.t1.Len() > p.p || (p.t1.Len() == p.p && p.hitB2) || p.t2.Len() == 0) {
+		from, ghost = p.t1, p.b1
+	}
+	e := from.Back()
+	if e == nil {
+		return *new(K), false
+	}
+	k := e.Value.(K)
+	p.move(k, ghost)
+	p.trim()
+	return k, true
+}
+
+func (p *arcPolicy[K]) Len() int {
+	return p.t1.Len() + p.t2.Len()
+}
+
+func (p *arcPolicy[K]) Reset() {
+	p.p = 0
+	p.hitB2 = false
+	p.t1, p.t2 = list.New(), list.New()
+	p.b1, p.b2 = list.New(), list.New()
+	p.items = make(map[K]*arcEntry)
+}
+
+// evict 淘汰元素直到不超过容量和cost 预算, 刚写入的keep 不会被淘汰, 调用方需持有 c.mu
+// keep 自己的cost 超过预算时只淘汰keep, 返回被淘汰的元素
+func (c *Cache[K, V]) evict(keep K) (evicted []removed[K]) {
+	if c.maxCost > 0 {
+		if x, ok := c.entries().Load(keep); ok && x.(*wrap).cost > c.maxCost {
+			c.policy.Remove(keep)
+			wp, _ := c.remove(keep)
+			c.stats.evictions.Add(1)
+			return []removed[K]{{k: keep, wp: wp, reason: ReasonCapacity}}
+		}
+	}
+	kept := false
+	for c.overflow(kept) {
+		k, ok := c.policy.Evict()
+		if !ok {
+			break
+		}
+		if k == keep {
+			kept = true
+			continue
+		}
+		if wp, ok := c.remove(k); ok {
+			c.stats.evictions.Add(1)
+			evicted = append(evicted, removed[K]{k: k, wp: wp, reason: ReasonCapacity})
+		}
+	}
+	if kept {
+		c.policy.Add(keep)
+	}
+	return evicted
+}
+
+// overflow 是否超过容量或cost 预算, kept 为true 时keep 已被策略移出但仍在cache中
+func (c *Cache[K, V]) overflow(kept bool) bool {
+	n := c.policy.Len()
+	if kept {
+		n++
+	}
+	if c.maxEntries > 0 && n > c.maxEntries {
+		return true
+	}
+	return c.maxCost > 0 && c.cost.Load() > c.maxCost
+}
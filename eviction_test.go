@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionLRU(t *testing.T) {
+	c := NewCache(WithMaxEntries[string, int](2))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Get(`a`)
+	c.Set(`c`, 3)
+	require.True(t, c.Has(`a`, `c`))
+	require.False(t, c.Has(`b`))
+	require.Equal(t, 2, c.Len())
+}
+
+func TestEvictionFIFO(t *testing.T) {
+	c := NewCache(WithMaxEntries[string, int](2), WithEvictionPolicy[string, int](NewFIFOPolicy[string]()))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Get(`a`)
+	c.Set(`c`, 3)
+	require.True(t, c.Has(`b`, `c`))
+	require.False(t, c.Has(`a`))
+}
+
+func TestEvictionLFU(t *testing.T) {
+	c := NewCache(WithMaxEntries[string, int](2), WithEvictionPolicy[string, int](NewLFUPolicy[string]()))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Get(`b`)
+	c.Get(`b`)
+	c.Get(`a`)
+	c.Set(`c`, 3)
+	require.True(t, c.Has(`b`, `c`))
+	require.False(t, c.Has(`a`))
+}
+
+func TestEvictionARC(t *testing.T) {
+	c := NewCache(WithMaxEntries[int, int](3), WithEvictionPolicy[int, int](NewARCPolicy[int]()))
+	c.Set(1, 1)
+	c.Set(2, 2)
+	c.Get(1)
+	c.Get(2)
+	// 3 4 5 只访问一次, 不应挤掉频繁访问的 1 2
+	for i := 3; i <= 5; i++ {
+		c.Set(i, i)
+	}
+	ks := c.ListKey()
+	sort.Ints(ks)
+	require.Equal(t, []int{1, 2, 5}, ks)
+}
+
+func TestEvictionARCCostOnly(t *testing.T) {
+	p := NewARCPolicy[int]().(*arcPolicy[int])
+	c := NewCache(
+		WithMaxCost[int, int](10),
+		WithWeigher(func(k, v int) int64 { return 1 }),
+		WithEvictionPolicy[int, int](p),
+	)
+	for i := 0; i < 10000; i++ {
+		c.Set(i, i)
+	}
+	require.Equal(t, 10, c.Len())
+	require.Equal(t, 10, p.Len())
+	require.LessOrEqual(t, p.b1.Len()+p.b2.Len(), 20)
+	require.Len(t, p.items, p.Len()+p.b1.Len()+p.b2.Len())
+}
+
+func TestEvictionDelClear(t *testing.T) {
+	c := NewCache(WithMaxEntries[string, int](2))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Del(`a`)
+	c.Set(`c`, 3)
+	require.True(t, c.Has(`b`, `c`))
+	c.Clear()
+	require.True(t, c.IsEmpty())
+	c.Set(`d`, 4)
+	c.Set(`e`, 5)
+	require.True(t, c.Has(`d`, `e`))
+}
@@ -0,0 +1,161 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"time"
+)
+
+// WithActiveExpiry 用按超时时间排序的最小堆和定时器管理过期, 元素到期后很快就会被删除
+// Clean 和 Len 只处理已经到期的元素, 不再遍历整个cache
+func WithActiveExpiry[K comparable, V any]() Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.expiry = &expiry[K]{}
+	}
+}
+
+// expiryItem 是过期堆中的元素
+type expiryItem[K comparable] struct {
+	k        K
+	wp       *wrap
+	deadline int64
+}
+
+// expiryHeap 按deadline 排序的最小堆, wrap.hidx 记录元素在堆中的位置
+type expiryHeap[K comparable] []*expiryItem[K]
+
+func (h expiryHeap[K]) Len() int { return len(h) }
+
+func (h expiryHeap[K]) Less(i, j int) bool { return h[i].deadline < h[j].deadline }
+
+func (h expiryHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].wp.hidx = i
+	h[j].wp.hidx = j
+}
+
+func (h *expiryHeap[K]) Push(x any) {
+	it := x.(*expiryItem[K])
+	it.wp.hidx = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	it.wp.hidx = -1
+	return it
+}
+
+// expiry 是cache 的过期管理
+type expiry[K comparable] struct {
+	mu    sync.Mutex
+	h     expiryHeap[K]
+	fire  func()
+	clock Clock
+	timer Timer
+	armed int64
+}
+
+// push 添加一个元素, 它成为最早到期的元素时重设定时器
+func (e *expiry[K]) push(k K, wp *wrap, deadline time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	it := &expiryItem[K]{k: k, wp: wp, deadline: deadline.UnixNano()}
+	heap.Push(&e.h, it)
+	if wp.hidx == 0 {
+		e.arm()
+	}
+}
+
+// remove 删除一个元素
+func (e *expiry[K]) remove(wp *wrap) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if wp.hidx < 0 || wp.hidx >= len(e.h) || e.h[wp.hidx].wp != wp {
+		return
+	}
+	heap.Remove(&e.h, wp.hidx)
+}
+
+// popDue 取出在now 之前到期的元素, limit > 0 时最多取出limit 个
+func (e *expiry[K]) popDue(now int64, limit int) (due []*expiryItem[K]) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for len(e.h) > 0 && e.h[0].deadline <= now && (limit <= 0 || len(due) < limit) {
+		due = append(due, heap.Pop(&e.h).(*expiryItem[K]))
+	}
+	return due
+}
+
+// next 返回最早的到期时间, 没有元素时返回 math.MaxInt64
+func (e *expiry[K]) next() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.h) == 0 {
+		return math.MaxInt64
+	}
+	return e.h[0].deadline
+}
+
+// rearm 按最早的到期时间重设定时器
+func (e *expiry[K]) rearm() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.arm()
+}
+
+// arm 设置定时器在堆顶元素到期时触发, 调用方需持有 e.mu
+func (e *expiry[K]) arm() {
+	if len(e.h) == 0 {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+		e.armed = 0
+		return
+	}
+	deadline := e.h[0].deadline
+	if deadline == e.armed {
+		return
+	}
+	e.armed = deadline
+	d := time.Unix(0, deadline).Sub(e.clock.Now())
+	if e.timer == nil {
+		e.timer = e.clock.AfterFunc(d, e.fire)
+		return
+	}
+	e.timer.Reset(d)
+}
+
+// reset 清空所有元素并停止定时器
+func (e *expiry[K]) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, it := range e.h {
+		it.wp.hidx = -1
+	}
+	e.h = nil
+	e.arm()
+}
+
+// expireDue 删除已经到期的元素并重设定时器, budget > 0 时最多删除budget 个
+func (c *Cache[K, V]) expireDue(budget int) {
+	due := c.expiry.popDue(c.clock.Now().UnixNano(), budget)
+	for _, it := range due {
+		c.delExpired(it.k, it.wp)
+	}
+	c.expiry.rearm()
+	c.nextExpiry.Store(c.expiry.next())
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveExpiry(t *testing.T) {
+	r := &evictRecorder{}
+	c := NewCache(
+		WithTTL[string, int](time.Millisecond*30),
+		WithActiveExpiry[string, int](),
+		WithOnEvict(r.record),
+		WithNoManager[string, int](),
+	)
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Del(`b`)
+	r.take()
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.events) > 0
+	}, time.Second, time.Millisecond*5)
+	require.Equal(t, []evictEvent{{`a`, 1, ReasonExpired}}, r.take())
+	require.Equal(t, 0, c.Len())
+	require.Empty(t, c.expiry.h)
+}
+
+func TestActiveExpiryReplace(t *testing.T) {
+	c := NewCache(
+		WithTTL[string, int](time.Hour),
+		WithActiveExpiry[string, int](),
+	)
+	for i := 0; i < 100; i++ {
+		c.Set(`a`, i)
+	}
+	require.Len(t, c.expiry.h, 1)
+	c.Clean()
+	require.Equal(t, 1, c.Len())
+	c.Clear()
+	require.Empty(t, c.expiry.h)
+}
@@ -0,0 +1,244 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weapons97/cache"
+	"github.com/weapons97/cache/cachetest"
+)
+
+func TestPersistenceDowntime(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	path := filepath.Join(t.TempDir(), `cache.snap`)
+	opts := []cache.Option[string, int]{
+		cache.WithTTL[string, int](time.Hour),
+		cache.WithClock[string, int](fc),
+		cache.WithPersistence[string, int](path, 0),
+		cache.WithNoManager[string, int](),
+	}
+	c := cache.NewCache(opts...)
+	c.Set(`a`, 1)
+	c.SetWithTTL(`b`, 2, time.Minute*10)
+	require.NoError(t, c.Save())
+
+	fc.Advance(time.Minute * 30)
+	r := cache.NewCache(opts...)
+	require.NoError(t, r.PersistError())
+	ttl, ok := r.TTL(`a`)
+	require.True(t, ok)
+	require.Equal(t, time.Minute*30, ttl)
+	require.False(t, r.Has(`b`))
+}
+
+func TestWriteLogCompactDeadline(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	path := filepath.Join(t.TempDir(), `cache.wal`)
+	opts := []cache.Option[int, int]{
+		cache.WithWriteLog[int, int](path, 256),
+		cache.WithClock[int, int](fc),
+		cache.WithNoManager[int, int](),
+	}
+	c := cache.NewCache(opts...)
+	c.SetWithTTL(0, 0, time.Hour)
+	for i := 1; i < 20; i++ {
+		c.Set(i, i)
+	}
+	require.NoError(t, c.Close())
+	_, err := os.Stat(path + `.snapshot`)
+	require.NoError(t, err)
+
+	fc.Advance(time.Minute * 50)
+	r := cache.NewCache(opts...)
+	ttl, ok := r.TTL(0)
+	require.True(t, ok)
+	require.Equal(t, time.Minute*10, ttl)
+	require.NoError(t, r.Close())
+
+	fc.Advance(time.Hour)
+	r = cache.NewCache(opts...)
+	require.False(t, r.Has(0))
+	require.True(t, r.Has(1))
+}
+
+// waitTimers 等待 FakeClock 上有n 个定时器, 定时器在其他goroutine 中创建时使用
+func waitTimers(t *testing.T, fc *cachetest.FakeClock, n int) {
+	require.Eventually(t, func() bool {
+		return fc.Timers() == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestSchedule(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	cm := cache.NewCacheManager(cache.WithManagerClock(fc))
+	n := atomic.Int32{}
+	count := func(ctx context.Context) error {
+		n.Add(1)
+		return nil
+	}
+	require.NoError(t, cm.Schedule(`count`, time.Second*5, count))
+	require.ErrorIs(t, cm.Schedule(`count`, time.Second, nil), cache.ErrDuplicateTask)
+	require.Error(t, cm.Schedule(`zero`, 0, count))
+	require.Error(t, cm.Schedule(`negative`, -time.Second, count))
+	fc.Advance(time.Minute)
+	require.Equal(t, int32(0), n.Load())
+
+	cm.Start(context.Background())
+	for i := int32(1); i <= 3; i++ {
+		waitTimers(t, fc, 2)
+		require.Equal(t, i, n.Load())
+		st, _ := cm.TaskStatus(`count`)
+		require.Equal(t, fc.Now().Add(time.Second*5), st.NextRun)
+		fc.Advance(time.Second * 5)
+	}
+	require.Eventually(t, func() bool {
+		return n.Load() == 4
+	}, time.Second, time.Millisecond)
+	cm.Stop()
+	st, ok := cm.TaskStatus(`count`)
+	require.True(t, ok)
+	require.Equal(t, uint64(4), st.Runs)
+	require.False(t, st.Running)
+	require.True(t, st.NextRun.IsZero())
+	require.NoError(t, st.LastError)
+	require.Equal(t, 0, fc.Timers())
+	fc.Advance(time.Minute)
+	require.Equal(t, int32(4), n.Load())
+
+	require.True(t, cm.Unschedule(`count`))
+	require.False(t, cm.Unschedule(`count`))
+	_, ok = cm.TaskStatus(`count`)
+	require.False(t, ok)
+}
+
+func TestScheduleFailures(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	cm := cache.NewCacheManager(cache.WithManagerClock(fc))
+	cm.Start(context.Background())
+	defer cm.Stop()
+	cm.Schedule(`panic`, time.Second, func(ctx context.Context) error {
+		panic(`oops`)
+	})
+	waitTimers(t, fc, 2)
+	fc.Advance(time.Second)
+	require.Eventually(t, func() bool {
+		st, _ := cm.TaskStatus(`panic`)
+		return st.Runs == 2
+	}, time.Second, time.Millisecond)
+	st, _ := cm.TaskStatus(`panic`)
+	require.Contains(t, st.LastError.Error(), `oops`)
+	require.Equal(t, uint64(2), st.Failures)
+	require.True(t, cm.Unschedule(`panic`))
+	waitTimers(t, fc, 1)
+
+	boom := errors.New(`boom`)
+	cm.Schedule(`backoff`, time.Second*10, func(ctx context.Context) error {
+		return boom
+	}, cache.WithTaskBackoff(time.Minute))
+	for _, wait := range []time.Duration{time.Second * 20, time.Second * 40, time.Minute, time.Minute} {
+		waitTimers(t, fc, 2)
+		st, _ = cm.TaskStatus(`backoff`)
+		require.Equal(t, fc.Now().Add(wait), st.NextRun)
+		fc.Advance(wait)
+	}
+	require.Eventually(t, func() bool {
+		st, _ := cm.TaskStatus(`backoff`)
+		return st.Runs == 5
+	}, time.Second, time.Millisecond)
+	st, _ = cm.TaskStatus(`backoff`)
+	require.ErrorIs(t, st.LastError, boom)
+	require.Equal(t, 5, st.ConsecutiveFailures)
+	require.Len(t, cm.ListTasks(), 1)
+	require.Equal(t, `backoff`, cm.ListTasks()[0].Name)
+}
+
+// countingCache 记录 Clean 的次数
+type countingCache struct {
+	n atomic.Int32
+}
+
+func (cc *countingCache) Clean() {
+	cc.n.Add(1)
+}
+
+func (cc *countingCache) Name() string {
+	return `counting`
+}
+
+func TestFakeClockManager(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	cm := cache.NewCacheManager(cache.WithManagerClock(fc), cache.WithCleanInterval(time.Second))
+	cc := &countingCache{}
+	cm.RegisterCache(cc)
+	fc.Advance(time.Second * 2)
+	require.Equal(t, int32(0), cc.n.Load())
+	cm.Start(context.Background())
+	defer cm.Stop()
+	fc.Advance(time.Millisecond * 500)
+	require.Equal(t, int32(0), cc.n.Load())
+	fc.Advance(time.Second * 3)
+	require.Equal(t, int32(3), cc.n.Load())
+}
+
+func TestManagerStartStop(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	cm := cache.NewCacheManager(cache.WithManagerClock(fc), cache.WithCleanInterval(time.Second))
+	cc := &countingCache{}
+	cm.RegisterCache(cc)
+	ctx, cancel := context.WithCancel(context.Background())
+	cm.Start(ctx)
+	cm.Start(ctx)
+	fc.Advance(time.Second)
+	require.Equal(t, int32(1), cc.n.Load())
+	cancel()
+	fc.Advance(time.Second * 3)
+	require.Equal(t, int32(1), cc.n.Load())
+
+	cm.Start(context.Background())
+	fc.Advance(time.Second)
+	require.Equal(t, int32(2), cc.n.Load())
+	cm.Stop()
+	fc.Advance(time.Second * 3)
+	require.Equal(t, int32(2), cc.n.Load())
+	require.Equal(t, 0, fc.Timers())
+
+	c := cache.NewCache(
+		cache.WithTTL[string, int](time.Second),
+		cache.WithClock[string, int](fc),
+		cache.WithManager[string, int](cm),
+		cache.WithName[string, int](`managed`),
+	)
+	found := false
+	cm.Range(func(ci cache.CacheI) bool {
+		found = found || ci == cache.CacheI(c)
+		return true
+	})
+	require.True(t, found)
+}
+
+func TestManagerCacheInterval(t *testing.T) {
+	fc := cachetest.NewFakeClock(time.Now())
+	cm := cache.NewCacheManager(cache.WithManagerClock(fc), cache.WithCleanInterval(time.Second), cache.WithCleanWorkers(2))
+	every := &countingCache{}
+	cm.RegisterCache(every)
+	slow := cache.NewCache(
+		cache.WithTTL[string, int](time.Second),
+		cache.WithClock[string, int](fc),
+		cache.WithManager[string, int](cm),
+		cache.WithCacheCleanInterval[string, int](time.Second*3),
+	)
+	slow.Set(`a`, 1)
+	cm.Start(context.Background())
+	defer cm.Stop()
+	fc.Advance(time.Second * 2)
+	require.Equal(t, int32(2), every.n.Load())
+	require.Equal(t, uint64(0), slow.Stats().Expirations)
+	fc.Advance(time.Second)
+	require.Equal(t, uint64(1), slow.Stats().Expirations)
+}
@@ -0,0 +1,163 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"sync"
+)
+
+// SetListener 是写入回调, existed 为true 时old 是被覆盖的旧值
+type SetListener[K comparable, V any] func(k K, old V, new V, existed bool)
+
+// ListenerOption 是回调的选项
+type ListenerOption func(*listenerConfig)
+
+// listenerConfig 回调的配置
+type listenerConfig struct {
+	async  bool
+	buffer int
+}
+
+// WithAsync 回调在单独的goroutine 中按顺序执行, 最多缓冲buffer 个事件, 缓冲满时写入方阻塞
+func WithAsync(buffer int) ListenerOption {
+	return func(cfg *listenerConfig) {
+		cfg.async = true
+		cfg.buffer = buffer
+	}
+}
+
+// listener 是一个注册的回调
+type listener[E any] struct {
+	fn   func(E)
+	ch   chan E
+	done chan struct{} // 取消后关闭, ch 不会被关闭, 避免向已关闭的channel 发送
+}
+
+// listeners 是可以在运行时注册和取消的回调集合
+type listeners[E any] struct {
+	mu  sync.RWMutex
+	seq int
+	m   map[int]*listener[E]
+}
+
+// add 注册回调, 返回取消注册的函数
+func (ls *listeners[E]) add(fn func(E), opts ...ListenerOption) (cancel func()) {
+	cfg := listenerConfig{}
+	for i := range opts {
+		opts[i](&cfg)
+	}
+	l := &listener[E]{fn: fn}
+	if cfg.async {
+		l.ch = make(chan E, cfg.buffer)
+		l.done = make(chan struct{})
+		go l.loop()
+	}
+	ls.mu.Lock()
+	if ls.m == nil {
+		ls.m = make(map[int]*listener[E])
+	}
+	ls.seq++
+	id := ls.seq
+	ls.m[id] = l
+	ls.mu.Unlock()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			ls.mu.Lock()
+			delete(ls.m, id)
+			ls.mu.Unlock()
+			if l.done != nil {
+				close(l.done)
+			}
+		})
+	}
+}
+
+// empty 是否没有回调
+func (ls *listeners[E]) empty() bool {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return len(ls.m) == 0
+}
+
+// loop 按顺序执行异步回调, 取消后处理完已缓冲的事件再退出
+func (l *listener[E]) loop() {
+	for {
+		select {
+		case e := <-l.ch:
+			l.fn(e)
+		case <-l.done:
+			for {
+				select {
+				case e := <-l.ch:
+					l.fn(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// emit 把事件发给所有回调, 回调执行时不持有锁, 回调中可以取消注册
+func (ls *listeners[E]) emit(e E) {
+	ls.mu.RLock()
+	all := make([]*listener[E], 0, len(ls.m))
+	for _, l := range ls.m {
+		all = append(all, l)
+	}
+	ls.mu.RUnlock()
+	for _, l := range all {
+		if l.ch != nil {
+			select {
+			case l.ch <- e:
+			case <-l.done:
+			}
+			continue
+		}
+		l.fn(e)
+	}
+}
+
+// setEvent 是一次写入
+type setEvent[K comparable, V any] struct {
+	k       K
+	old     V
+	new     V
+	existed bool
+}
+
+// OnSet 注册 Set 的回调, 返回的函数用于取消注册
+func (c *Cache[K, V]) OnSet(fn SetListener[K, V], opts ...ListenerOption) (cancel func()) {
+	return c.onSet.add(func(e setEvent[K, V]) {
+		fn(e.k, e.old, e.new, e.existed)
+	}, opts...)
+}
+
+// notifySet 调用 Set 的回调, prev 是被覆盖的元素
+func (c *Cache[K, V]) notifySet(k K, v V, prev *wrap) {
+	if c.onSet.empty() {
+		return
+	}
+	e := setEvent[K, V]{k: k, new: v}
+	if prev != nil {
+		if old, ok := c.unWrapTTL(prev); ok {
+			e.old, e.existed = old.(V), true
+		}
+	}
+	c.onSet.emit(e)
+}
+
+// OnSet 注册 Indexer.Set 的回调, 返回的函数用于取消注册
+func (ix *Indexer[T]) OnSet(fn SetListener[string, T], opts ...ListenerOption) (cancel func()) {
+	return ix.onSet.add(func(e setEvent[string, T]) {
+		fn(e.k, e.old, e.new, e.existed)
+	}, opts...)
+}
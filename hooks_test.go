@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOnSet(t *testing.T) {
+	c := NewCache[string, int]()
+	type event struct {
+		k        string
+		old, new int
+		existed  bool
+	}
+	events := []event{}
+	cancel := c.OnSet(func(k string, old, new int, existed bool) {
+		events = append(events, event{k, old, new, existed})
+	})
+	c.Set(`a`, 1)
+	c.Set(`a`, 2)
+	cancel()
+	c.Set(`a`, 3)
+	require.Equal(t, []event{{`a`, 0, 1, false}, {`a`, 1, 2, true}}, events)
+}
+
+func TestCacheOnSetAsync(t *testing.T) {
+	c := NewCache[string, int]()
+	mu := sync.Mutex{}
+	sum := 0
+	cancel := c.OnSet(func(k string, old, new int, existed bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		sum += new
+	}, WithAsync(1))
+	defer cancel()
+	for i := 1; i <= 10; i++ {
+		c.Set(`a`, i)
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return sum == 55
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestIndexerOnSet(t *testing.T) {
+	ix := NewIndexer[*Person]()
+	olds := []*Person{}
+	cancel := ix.OnSet(func(id string, old, new *Person, existed bool) {
+		if existed {
+			olds = append(olds, old)
+		}
+	})
+	defer cancel()
+	ix.Set(p1)
+	ix.Set(p2)
+	ix.Set(p1)
+	require.Equal(t, []*Person{p1}, olds)
+}
+
+func TestListenerSelfCancel(t *testing.T) {
+	c := NewCache[string, int]()
+	n := 0
+	var cancel func()
+	cancel = c.OnSet(func(k string, old, new int, existed bool) {
+		n++
+		cancel()
+	})
+	c.Set(`a`, 1)
+	c.Set(`a`, 2)
+	require.Equal(t, 1, n)
+
+	block := make(chan struct{})
+	var cancelAsync func()
+	cancelAsync = c.OnSet(func(k string, old, new int, existed bool) {
+		<-block
+	}, WithAsync(1))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			c.Set(`b`, i)
+		}
+	}()
+	time.Sleep(time.Millisecond * 10)
+	cancelAsync()
+	close(block)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal(`writer blocked after cancel`)
+	}
+}
@@ -0,0 +1,47 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"time"
+)
+
+// WithIdleTimeout 设置空闲超时, 元素idle 时间内没有被 Get 就会超时
+// 同时设置了 WithTTL 时, 元素最长存活ttl 时间
+func WithIdleTimeout[K comparable, V any](idle time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.idleTimeout = idle
+	}
+}
+
+// WithIdleTouchOnHas Has 和 HasAny 也会延长空闲超时
+func WithIdleTouchOnHas[K comparable, V any]() Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.idleTouchHas = true
+	}
+}
+
+// has 判断key 是否存在, 设置了 WithIdleTouchOnHas 才会延长空闲超时
+func (c *Cache[K, V]) has(k K) bool {
+	if c.idleTimeout > 0 && !c.idleTouchHas {
+		_, ok := c.get(k, false)
+		return ok
+	}
+	_, ok := c.Get(k)
+	return ok
+}
+
+// touch 元素被访问后延长空闲超时, 不超过最长存活时间
+func (c *Cache[K, V]) touch(k K, old *wrap) {
+	timeout := minTime(c.clock.Now().Add(c.idleTimeout), old.hard)
+	if !timeout.After(old.timeout) {
+		return
+	}
+	c.swapWrap(k, old, &wrap{timeout: timeout, v: old.v, cost: old.cost, hard: old.hard})
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	c := NewCache(WithIdleTimeout[string, int](time.Millisecond * 50))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	for i := 0; i < 4; i++ {
+		time.Sleep(time.Millisecond * 20)
+		_, ok := c.Get(`a`)
+		require.True(t, ok)
+		// Has 默认不延长超时
+		c.Has(`b`)
+	}
+	require.True(t, c.Has(`a`))
+	require.False(t, c.Has(`b`))
+}
+
+func TestIdleTimeoutWithTTL(t *testing.T) {
+	c := NewCache(
+		WithIdleTimeout[string, int](time.Millisecond*40),
+		WithTTL[string, int](time.Millisecond*70),
+		WithIdleTouchOnHas[string, int](),
+	)
+	c.Set(`a`, 1)
+	time.Sleep(time.Millisecond * 30)
+	require.True(t, c.Has(`a`))
+	time.Sleep(time.Millisecond * 30)
+	require.True(t, c.Has(`a`))
+	time.Sleep(time.Millisecond * 20)
+	require.False(t, c.Has(`a`))
+}
@@ -9,6 +9,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
@@ -50,13 +51,19 @@ func (ix *Indexer[T]) Remove(v ...T) {
 	}
 }
 
-func (ix *Indexer[T]) Pop() T {
-	ks, vs := ix.List()
-	if len(ks) == 0 {
-		return *new(T)
-	}
-	ix.Del(ks[0])
-	return vs[0]
+// Pop 取出并删除一个元素, 并发调用时同一个元素只会被一个调用方取到
+func (ix *Indexer[T]) Pop() (res T) {
+	ix.main.Range(func(id string, _ T) bool {
+		v, ok := ix.main.GetAndDelete(id)
+		if !ok {
+			return true
+		}
+		ix.unindex(v)
+		ix.emitWatch(Event[T]{Type: EventDeleted, Object: v})
+		res = v
+		return false
+	})
+	return res
 }
 
 func (ix *Indexer[T]) Has(v ...T) bool {
@@ -103,6 +110,11 @@ type Indexer[T Indexed] struct {
 	rw   sync.RWMutex
 	main *Cache[string, T] // 主表
 	opts []Option[string, T]
+
+	onSet listeners[setEvent[string, T]]
+
+	watchers   map[*watcher[T]]struct{}
+	watchersMu sync.RWMutex
 }
 
 // NewIndexer 创建一个带索引的cache
@@ -111,18 +123,59 @@ func NewIndexer[T Indexed](ops ...Option[string, T]) *Indexer[T] {
 	ix.cs = make(map[string]*Cache[string, *Set[string]])
 	ix.rw = sync.RWMutex{}
 	ix.opts = ops
-	ix.main = NewCache[string, T](ops...)
+	ix.main = ix.newMain()
 	return ix
 }
 
+// newMain 创建主表, 主表的元素过期或被淘汰时同步清理索引
+// 主表设置了 WithPersistence 时为加载的元素建立索引
+func (ix *Indexer[T]) newMain() *Cache[string, T] {
+	opts := append(ix.opts[:len(ix.opts):len(ix.opts)], WithOnEvict(ix.onMainEvict))
+	main := NewCache[string, T](opts...)
+	main.Range(func(id string, v T) bool {
+		ix.index(id, v)
+		return true
+	})
+	return main
+}
+
+// onMainEvict 主表元素过期或被淘汰时清理索引并通知watcher
+func (ix *Indexer[T]) onMainEvict(id string, v T, reason Reason) {
+	switch reason {
+	case ReasonExpired:
+		ix.unindex(v)
+		ix.emitWatch(Event[T]{Type: EventExpired, Object: v})
+	case ReasonCapacity:
+		ix.unindex(v)
+		ix.emitWatch(Event[T]{Type: EventDeleted, Object: v})
+	}
+}
+
 // Set 设置值，v 必须和 Indexer 的type相同
 func (ix *Indexer[T]) Set(v T) bool {
 	id := v.ID()
 	if ix.main == nil {
-		ix.main = NewCache[string, T](ix.opts...)
+		ix.main = ix.newMain()
+	}
+	old, existed := ix.main.Get(id)
+	if existed {
+		ix.del(old)
 	}
-	ix.Del(id)
 	ix.main.Set(id, v)
+	ix.index(id, v)
+	if !ix.onSet.empty() {
+		ix.onSet.emit(setEvent[string, T]{k: id, old: old, new: v, existed: existed})
+	}
+	if existed {
+		ix.emitWatch(Event[T]{Type: EventUpdated, Object: v, Old: old})
+	} else {
+		ix.emitWatch(Event[T]{Type: EventAdded, Object: v})
+	}
+	return true
+}
+
+// index 把v 加入索引表
+func (ix *Indexer[T]) index(id string, v T) {
 	idxs := v.Indexes()
 	for name, idx := range idxs {
 		keys := idx(v)
@@ -142,7 +195,6 @@ func (ix *Indexer[T]) Set(v T) bool {
 			c.Set(key, set)
 		}
 	}
-	return true
 }
 
 // Len 返回cache 长度
@@ -167,31 +219,38 @@ func (ix *Indexer[T]) Del(v interface{}) {
 		if !ok {
 			return
 		}
-		ix.del(v2)
+		if ix.del(v2) {
+			ix.emitWatch(Event[T]{Type: EventDeleted, Object: v2})
+		}
 	}
 	v2, ok := v.(T)
-	if ok {
-		ix.del(v2)
+	if ok && ix.del(v2) {
+		ix.emitWatch(Event[T]{Type: EventDeleted, Object: v2})
 	}
 }
 
-func (ix *Indexer[T]) del(req Indexed) {
-	id := req.ID()
+// del 从主表删除req 并清理索引, 返回主表中是否有这个元素
+func (ix *Indexer[T]) del(req Indexed) bool {
 	if ix.main == nil {
-		return
+		return false
 	}
-	c := ix.main
-	c.Del(id)
+	_, ok := ix.main.GetAndDelete(req.ID())
+	ix.unindex(req)
+	return ok
+}
+
+// unindex 从索引表中删除一个Indexed
+func (ix *Indexer[T]) unindex(req Indexed) {
+	id := req.ID()
 	idxs := req.Indexes()
 	for name, idx := range idxs {
 		keys := idx(req)
 		ix.rw.RLock()
-		if _, ok := ix.cs[name]; !ok {
-			ix.rw.RUnlock()
+		c, ok := ix.cs[name]
+		ix.rw.RUnlock()
+		if !ok {
 			continue
 		}
-		ix.rw.RUnlock()
-		c := ix.cs[name]
 		for _, key := range keys {
 			set, ok := c.Get(key)
 			// set, ok2 := v.(*Set)
@@ -300,7 +359,16 @@ func (sr *SearchResult[T]) Range(fn func(v T) bool) {
 
 // Search 根据索引函数查找Indexer
 func (ix *Indexer[T]) Search(idxName string, key string) *SearchResult[T] {
+	if !ix.main.traced() {
+		vs, e := ix.search(idxName, key)
+		return &SearchResult[T]{
+			e:   e,
+			Res: vs,
+		}
+	}
+	_, sp := ix.main.startSpan(context.Background(), `indexer.Search`, key, Attr(`index.name`, idxName))
 	vs, e := ix.search(idxName, key)
+	sp.end(Attr(`cache.hit`, e == nil), Attr(`index.results`, len(vs)))
 	return &SearchResult[T]{
 		e:   e,
 		Res: vs,
@@ -0,0 +1,74 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+// Reason 是元素离开cache 的原因
+type Reason int
+
+const (
+	// ReasonExplicit 被 Del 或 Remove 删除
+	ReasonExplicit Reason = iota
+	// ReasonExpired 超时被删除
+	ReasonExpired
+	// ReasonReplaced 被 Set 覆盖
+	ReasonReplaced
+	// ReasonCapacity 超过容量或cost 预算被淘汰
+	ReasonCapacity
+	// ReasonCleared 被 Clear 清空
+	ReasonCleared
+	// ReasonTaken 被 TakeAll 取出
+	ReasonTaken
+)
+
+// String 返回Reason 的名称
+func (r Reason) String() string {
+	switch r {
+	case ReasonExplicit:
+		return `explicit`
+	case ReasonExpired:
+		return `expired`
+	case ReasonReplaced:
+		return `replaced`
+	case ReasonCapacity:
+		return `capacity`
+	case ReasonCleared:
+		return `cleared`
+	case ReasonTaken:
+		return `taken`
+	}
+	return `unknown`
+}
+
+// WithOnEvict 设置元素离开cache 时的回调, 可以设置多个
+// 回调在cache 的锁外同步调用
+func WithOnEvict[K comparable, V any](fn func(K, V, Reason)) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.onEvict = append(cache.onEvict, fn)
+	}
+}
+
+// removed 是一个离开cache 的元素
+type removed[K comparable] struct {
+	k      K
+	wp     *wrap
+	reason Reason
+}
+
+// notifyEvict 调用离开cache 的回调
+func (c *Cache[K, V]) notifyEvict(rs ...removed[K]) {
+	if len(c.onEvict) == 0 {
+		return
+	}
+	for _, r := range rs {
+		v, _ := r.wp.v.(V)
+		for _, fn := range c.onEvict {
+			fn(r.k, v, r.reason)
+		}
+	}
+}
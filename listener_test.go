@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type evictEvent struct {
+	k      string
+	v      int
+	reason Reason
+}
+
+type evictRecorder struct {
+	mu     sync.Mutex
+	events []evictEvent
+}
+
+func (r *evictRecorder) record(k string, v int, reason Reason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, evictEvent{k, v, reason})
+}
+
+func (r *evictRecorder) take() []evictEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := r.events
+	r.events = nil
+	return res
+}
+
+func TestOnEvict(t *testing.T) {
+	r := &evictRecorder{}
+	c := NewCache(WithMaxEntries[string, int](2), WithOnEvict(r.record))
+	c.Set(`a`, 1)
+	c.Set(`a`, 2)
+	require.Equal(t, []evictEvent{{`a`, 1, ReasonReplaced}}, r.take())
+	c.Set(`b`, 3)
+	c.Set(`c`, 4)
+	require.Equal(t, []evictEvent{{`a`, 2, ReasonCapacity}}, r.take())
+	c.Del(`b`)
+	c.Remove(`x`)
+	require.Equal(t, []evictEvent{{`b`, 3, ReasonExplicit}}, r.take())
+	c.Clear()
+	require.Equal(t, []evictEvent{{`c`, 4, ReasonCleared}}, r.take())
+}
+
+func TestOnEvictExpired(t *testing.T) {
+	r := &evictRecorder{}
+	c := NewCache(WithTTL[string, int](time.Millisecond*20), WithOnEvict(r.record))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	time.Sleep(time.Millisecond * 30)
+	_, ok := c.Get(`a`)
+	require.False(t, ok)
+	require.Equal(t, []evictEvent{{`a`, 1, ReasonExpired}}, r.take())
+	c.Clean()
+	require.Equal(t, []evictEvent{{`b`, 2, ReasonExpired}}, r.take())
+	c.Clean()
+	require.Empty(t, r.take())
+}
@@ -0,0 +1,162 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoLoader cache 没有设置 Loader 时 GetOrLoad 返回的错误
+var ErrNoLoader = errors.New(`cache has no loader`)
+
+// ErrLoaderPanic 是 Loader panic 时 GetOrLoad 返回的错误
+var ErrLoaderPanic = errors.New(`cache loader panic`)
+
+// Loader 在cache 未命中时加载key 对应的值
+type Loader[K comparable, V any] func(ctx context.Context, k K) (V, error)
+
+// WithLoader 设置cache 的 Loader, 配合 GetOrLoad 使用
+func WithLoader[K comparable, V any](loader Loader[K, V]) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.loader = loader
+	}
+}
+
+// WithNegativeTTL 缓存 Loader 返回的错误ttl 时间, 期间 GetOrLoad 直接返回该错误
+// context.Canceled 和 context.DeadlineExceeded 不会被缓存
+func WithNegativeTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.negativeTTL = ttl
+	}
+}
+
+// loadCall 是一次正在进行的加载
+type loadCall[V any] struct {
+	done chan struct{}
+	v    V
+	e    error
+}
+
+// loadGroup 合并同一个key 的并发加载, 保证同时只有一个 Loader 在运行
+type loadGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*loadCall[V]
+}
+
+// do 在单独的goroutine 中调用fn 加载k, 同一个k 的调用方等待同一次加载
+// 每个调用方的ctx 结束时它不再等待, 加载继续进行, fn panic 时所有调用方都得到 ErrLoaderPanic
+func (g *loadGroup[K, V]) do(ctx context.Context, k K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*loadCall[V])
+	}
+	call, ok := g.calls[k]
+	if !ok {
+		call = &loadCall[V]{done: make(chan struct{})}
+		g.calls[k] = call
+		go g.run(k, call, fn)
+	}
+	g.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.v, call.e
+	case <-ctx.Done():
+		return *new(V), ctx.Err()
+	}
+}
+
+// run 执行一次加载, 完成后唤醒所有等待的调用方
+func (g *loadGroup[K, V]) run(k K, call *loadCall[V], fn func() (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.e = fmt.Errorf(`%w: %v`, ErrLoaderPanic, r)
+		}
+		g.mu.Lock()
+		delete(g.calls, k)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.v, call.e = fn()
+}
+
+// negative 是被缓存的加载错误
+type negative struct {
+	timeout time.Time
+	e       error
+}
+
+// GetOrLoad 根据key获得value, 未命中时调用 Loader 加载并以cache 的ttl 保存
+// 同一个key 的并发加载只会调用一次 Loader, 加载错误不会被保存(除非设置了 WithNegativeTTL)
+// Loader 使用不会被取消的ctx, 调用方的ctx 结束时 GetOrLoad 返回ctx 的错误, 加载在后台继续
+// 设置了 WithStaleWhileRevalidate 时宽限期内的过期值会直接返回并在后台刷新
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, k K) (V, error) {
+	if v, _, ok := c.GetStale(k); ok {
+		return v, nil
+	}
+	if c.loader == nil {
+		return *new(V), ErrNoLoader
+	}
+	if e := c.negativeErr(k); e != nil {
+		return *new(V), e
+	}
+	return c.loads.do(ctx, k, func() (V, error) {
+		// 等待期间其他调用方可能已经写入, 这次查找不计入统计
+		if wp, alive := c.current(k); alive {
+			return wp.v.(V), nil
+		}
+		start := time.Now()
+		ctx, sp := c.startSpan(context.WithoutCancel(ctx), `cache.Load`, k)
+		v, e := c.loader(ctx, k)
+		if sp != nil {
+			sp.end(Attr(`cache.error`, e != nil))
+		}
+		c.stats.recordLoad(start, e)
+		if e != nil {
+			if c.negativeTTL > 0 && !errors.Is(e, context.Canceled) && !errors.Is(e, context.DeadlineExceeded) {
+				c.negatives.Store(k, &negative{timeout: c.clock.Now().Add(c.negativeTTL), e: e})
+			}
+			return v, e
+		}
+		c.negatives.Delete(k)
+		c.Set(k, v)
+		return v, nil
+	})
+}
+
+// cleanNegatives 删除过期的加载错误
+func (c *Cache[K, V]) cleanNegatives() {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	now := c.clock.Now()
+	c.negatives.Range(func(k, nv any) bool {
+		if now.After(nv.(*negative).timeout) {
+			c.negatives.CompareAndDelete(k, nv)
+		}
+		return true
+	})
+}
+
+// negativeErr 返回未过期的加载错误, 没有则返回nil
+func (c *Cache[K, V]) negativeErr(k K) error {
+	nv, ok := c.negatives.Load(k)
+	if !ok {
+		return nil
+	}
+	ng := nv.(*negative)
+	if c.clock.Now().After(ng.timeout) {
+		c.negatives.CompareAndDelete(k, nv)
+		return nil
+	}
+	return ng.e
+}
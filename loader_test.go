@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	var calls atomic.Int32
+	c := NewCache(WithLoader(func(ctx context.Context, k string) (int, error) {
+		calls.Add(1)
+		time.Sleep(time.Millisecond * 50)
+		return len(k), nil
+	}))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, e := c.GetOrLoad(context.Background(), `abc`)
+			require.NoError(t, e)
+			require.Equal(t, 3, v)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), calls.Load())
+	v, ok := c.Get(`abc`)
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	errLoad := errors.New(`load failed`)
+	var calls atomic.Int32
+	loader := func(ctx context.Context, k string) (int, error) {
+		calls.Add(1)
+		return 0, errLoad
+	}
+	c := NewCache(WithLoader(loader))
+	_, e := c.GetOrLoad(context.Background(), `a`)
+	require.ErrorIs(t, e, errLoad)
+	require.False(t, c.Has(`a`))
+	_, e = c.GetOrLoad(context.Background(), `a`)
+	require.ErrorIs(t, e, errLoad)
+	require.Equal(t, int32(2), calls.Load())
+
+	calls.Store(0)
+	n := NewCache(WithLoader(loader), WithNegativeTTL[string, int](time.Hour))
+	_, e = n.GetOrLoad(context.Background(), `a`)
+	require.ErrorIs(t, e, errLoad)
+	_, e = n.GetOrLoad(context.Background(), `a`)
+	require.ErrorIs(t, e, errLoad)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestNegativeClean(t *testing.T) {
+	loader := func(ctx context.Context, k int) (int, error) {
+		return 0, errors.New(`load failed`)
+	}
+	c := NewCache(WithLoader(loader), WithNegativeTTL[int, int](time.Millisecond), WithNoManager[int, int]())
+	for i := 0; i < 100; i++ {
+		c.GetOrLoad(context.Background(), i)
+	}
+	n := func() int {
+		i := 0
+		c.negatives.Range(func(k, v any) bool {
+			i++
+			return true
+		})
+		return i
+	}
+	require.Equal(t, 100, n())
+	time.Sleep(time.Millisecond * 5)
+	c.Clean()
+	require.Equal(t, 0, n())
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	release := make(chan struct{})
+	c := NewCache(WithLoader(func(ctx context.Context, k string) (int, error) {
+		<-release
+		panic(`boom`)
+	}))
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, e := c.GetOrLoad(context.Background(), `a`)
+			errs <- e
+		}()
+	}
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	for i := 0; i < 5; i++ {
+		e := <-errs
+		require.ErrorIs(t, e, ErrLoaderPanic)
+		require.Contains(t, e.Error(), `boom`)
+	}
+	require.False(t, c.Has(`a`))
+}
+
+func TestGetOrLoadCancel(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	c := NewCache(WithLoader(func(ctx context.Context, k string) (int, error) {
+		calls.Add(1)
+		<-release
+		if e := ctx.Err(); e != nil {
+			return 0, e
+		}
+		return 1, nil
+	}), WithNegativeTTL[string, int](time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, e := c.GetOrLoad(ctx, `a`)
+		first <- e
+	}()
+	second := make(chan int, 1)
+	go func() {
+		v, _ := c.GetOrLoad(context.Background(), `a`)
+		second <- v
+	}()
+	time.Sleep(time.Millisecond * 10)
+	cancel()
+	require.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	require.Equal(t, 1, <-second)
+	require.Equal(t, int32(1), calls.Load())
+
+	calls.Store(0)
+	d := NewCache(WithLoader(func(ctx context.Context, k string) (int, error) {
+		calls.Add(1)
+		return 0, context.DeadlineExceeded
+	}), WithNegativeTTL[string, int](time.Hour))
+	d.GetOrLoad(context.Background(), `a`)
+	d.GetOrLoad(context.Background(), `a`)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestGetOrLoadNoLoader(t *testing.T) {
+	c := NewCache[string, int]()
+	_, e := c.GetOrLoad(context.Background(), `a`)
+	require.ErrorIs(t, e, ErrNoLoader)
+}
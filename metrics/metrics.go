@@ -0,0 +1,141 @@
+// Package metrics 以Prometheus 文本格式导出 CacheManager 中所有cache 的统计信息
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/weapons97/cache"
+)
+
+// contentType 是Prometheus 文本格式的Content-Type
+const contentType = `text/plain; version=0.0.4; charset=utf-8`
+
+// StatsProvider 是可以导出统计信息的cache, Cache 和 Indexer 都实现了这个接口
+type StatsProvider interface {
+	Name() string
+	Stats() cache.Stats
+}
+
+// CostProvider 是可以导出总cost 的cache
+type CostProvider interface {
+	Cost() int64
+}
+
+// metric 是一个指标的定义
+type metric struct {
+	name  string
+	help  string
+	kind  string
+	value func(p StatsProvider, s cache.Stats) (float64, bool)
+}
+
+var metrics = []metric{
+	{`cache_hits_total`, `Number of cache hits.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Hits), true
+	}},
+	{`cache_misses_total`, `Number of cache misses.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Misses), true
+	}},
+	{`cache_sets_total`, `Number of cache sets.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Sets), true
+	}},
+	{`cache_deletes_total`, `Number of entries explicitly deleted.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Deletes), true
+	}},
+	{`cache_expirations_total`, `Number of entries removed after expiry.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Expirations), true
+	}},
+	{`cache_evictions_total`, `Number of entries evicted by capacity.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Evictions), true
+	}},
+	{`cache_loads_total`, `Number of loader calls.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Loads), true
+	}},
+	{`cache_load_errors_total`, `Number of loader calls that returned an error.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.LoadErrors), true
+	}},
+	{`cache_load_seconds_total`, `Total time spent in loader calls.`, `counter`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return s.LoadTime.Seconds(), true
+	}},
+	{`cache_size`, `Number of entries in the cache.`, `gauge`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return float64(s.Size), true
+	}},
+	{`cache_hit_ratio`, `Ratio of hits to lookups.`, `gauge`, func(_ StatsProvider, s cache.Stats) (float64, bool) {
+		return s.HitRatio(), true
+	}},
+	{`cache_cost`, `Total cost of the entries in the cache.`, `gauge`, func(p StatsProvider, _ cache.Stats) (float64, bool) {
+		cp, ok := p.(CostProvider)
+		if !ok {
+			return 0, false
+		}
+		return float64(cp.Cost()), true
+	}},
+}
+
+// sample 是一个cache 的统计快照
+type sample struct {
+	p StatsProvider
+	s cache.Stats
+}
+
+// collect 按名称顺序收集cm 中所有实现了 StatsProvider 的cache
+func collect(cm cache.CacheManager) []sample {
+	res := make([]sample, 0)
+	cm.Range(func(c cache.CacheI) bool {
+		if p, ok := c.(StatsProvider); ok {
+			res = append(res, sample{p: p, s: p.Stats()})
+		}
+		return true
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].p.Name() < res[j].p.Name()
+	})
+	return res
+}
+
+// Write 把cm 中所有cache 的指标以Prometheus 文本格式写入w
+func Write(w io.Writer, cm cache.CacheManager) error {
+	samples := collect(cm)
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		header := false
+		for _, sp := range samples {
+			v, ok := m.value(sp.p, sp.s)
+			if !ok {
+				continue
+			}
+			if !header {
+				bw.WriteString(`# HELP ` + m.name + ` ` + m.help + "\n")
+				bw.WriteString(`# TYPE ` + m.name + ` ` + m.kind + "\n")
+				header = true
+			}
+			bw.WriteString(m.name + `{cache="` + escapeLabel(sp.p.Name()) + `"} `)
+			bw.WriteString(strconv.FormatFloat(v, 'g', -1, 64) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// escapeLabel 转义label 的值
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// Handler 返回导出cm 指标的http.Handler, cm 为nil 时使用 cache.CacheManagerFactory
+func Handler(cm cache.CacheManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := cm
+		if m == nil {
+			m = cache.CacheManagerFactory()
+		}
+		w.Header().Set(`Content-Type`, contentType)
+		if e := Write(w, m); e != nil {
+			http.Error(w, e.Error(), http.StatusInternalServerError)
+		}
+	})
+}
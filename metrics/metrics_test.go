@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/weapons97/cache"
+)
+
+func TestHandler(t *testing.T) {
+	c := cache.NewCache(
+		cache.WithTTL[string, int](time.Hour),
+		cache.WithName[string, int](`metrics"test`),
+	)
+	c.Set(`a`, 1)
+	c.Get(`a`)
+	c.Get(`b`)
+
+	srv := httptest.NewServer(Handler(nil))
+	defer srv.Close()
+	resp, e := srv.Client().Get(srv.URL)
+	require.NoError(t, e)
+	defer resp.Body.Close()
+	require.Equal(t, contentType, resp.Header.Get(`Content-Type`))
+	body, e := io.ReadAll(resp.Body)
+	require.NoError(t, e)
+	out := string(body)
+
+	require.Contains(t, out, "# TYPE cache_hits_total counter\n")
+	require.Contains(t, out, `cache_hits_total{cache="metrics\"test"} 1`+"\n")
+	require.Contains(t, out, `cache_misses_total{cache="metrics\"test"} 1`+"\n")
+	require.Contains(t, out, `cache_hit_ratio{cache="metrics\"test"} 0.5`+"\n")
+	require.Contains(t, out, `cache_size{cache="metrics\"test"} 1`+"\n")
+	require.Contains(t, out, `cache_cost{cache="metrics\"test"} 1`+"\n")
+	require.Equal(t, 1, strings.Count(out, "# TYPE cache_size gauge\n"))
+}
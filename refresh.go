@@ -0,0 +1,88 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// WithRefreshAhead 元素在过期前window 时间内被访问时, 后台用 Loader 重新加载
+func WithRefreshAhead[K comparable, V any](window time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.refreshAhead = window
+	}
+}
+
+// WithStaleWhileRevalidate 元素过期后grace 时间内仍会保留, GetStale 和 GetOrLoad
+// 可以返回这个过期的值, 同时后台用 Loader 重新加载
+func WithStaleWhileRevalidate[K comparable, V any](grace time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.staleGrace = grace
+	}
+}
+
+// GetStale 根据key获得value, stale 为true 表示值已过期但仍在宽限期内, 此时会触发后台刷新
+func (c *Cache[K, V]) GetStale(k K) (v V, stale bool, ok bool) {
+	if v, ok = c.Get(k); ok {
+		return v, false, true
+	}
+	if c.staleGrace <= 0 || c.entries() == nil {
+		return v, false, false
+	}
+	x, ok := c.entries().Load(k)
+	if !ok {
+		return v, false, false
+	}
+	wp := x.(*wrap)
+	if c.clock.Now().After(wp.timeout.Add(c.staleGrace)) {
+		return v, false, false
+	}
+	c.refresh(k)
+	return wp.v.(V), true, true
+}
+
+// maybeRefresh 元素快过期时触发后台刷新
+func (c *Cache[K, V]) maybeRefresh(k K, wp *wrap) {
+	if c.refreshAhead <= 0 || c.loader == nil {
+		return
+	}
+	if wp.timeout.Sub(c.clock.Now()) < c.refreshAhead {
+		c.refresh(k)
+	}
+}
+
+// refresh 后台用 Loader 重新加载key, 同一个key 同时只会有一个刷新
+func (c *Cache[K, V]) refresh(k K) {
+	if c.loader == nil {
+		return
+	}
+	if _, loaded := c.refreshing.LoadOrStore(k, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer c.refreshing.Delete(k)
+		start := time.Now()
+		ctx, sp := c.startSpan(context.Background(), `cache.Refresh`, k)
+		v, e := c.loader(ctx, k)
+		if sp != nil {
+			sp.end(Attr(`cache.error`, e != nil))
+		}
+		c.stats.recordLoad(start, e)
+		if e != nil {
+			return
+		}
+		c.Set(k, v)
+	}()
+}
+
+// expired 元素是否已经过期并超出宽限期, 可以被删除
+func (c *Cache[K, V]) expired(wp *wrap) bool {
+	return c.clock.Now().After(wp.timeout.Add(c.staleGrace))
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	var n atomic.Int32
+	c := NewCache(
+		WithTTL[string, int32](time.Second),
+		WithRefreshAhead[string, int32](time.Second*2),
+		WithLoader(func(ctx context.Context, k string) (int32, error) {
+			return n.Add(1), nil
+		}),
+		WithNoManager[string, int32](),
+	)
+	c.Set(`a`, 0)
+	v, ok := c.Get(`a`)
+	require.True(t, ok)
+	require.Equal(t, int32(0), v)
+	require.Eventually(t, func() bool {
+		v, _ := c.Get(`a`)
+		return v > 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	c := NewCache(
+		WithTTL[string, int](time.Millisecond*50),
+		WithStaleWhileRevalidate[string, int](time.Hour),
+		WithLoader(func(ctx context.Context, k string) (int, error) {
+			return 2, nil
+		}),
+		WithNoManager[string, int](),
+	)
+	c.Set(`a`, 1)
+	time.Sleep(time.Millisecond * 60)
+	_, ok := c.Get(`a`)
+	require.False(t, ok)
+	c.Clean()
+	v, stale, ok := c.GetStale(`a`)
+	require.True(t, ok)
+	require.True(t, stale)
+	require.Equal(t, 1, v)
+	require.Eventually(t, func() bool {
+		v, ok := c.Get(`a`)
+		return ok && v == 2
+	}, time.Second, time.Millisecond*10)
+}
@@ -107,11 +107,14 @@ func (s *Set[K]) HasAny(items ...K) bool {
 	return false
 }
 
+// Pop 取出并删除一个元素, 并发调用时同一个元素只会被一个调用方取到
 func (s *Set[K]) Pop() (res K) {
 	s.inner.Range(func(k K, v struct{}) bool {
-		res = k
-		s.inner.Del(k)
-		return false
+		if _, ok := s.inner.GetAndDelete(k); ok {
+			res = k
+			return false
+		}
+		return true
 	})
 	return res
 }
@@ -0,0 +1,275 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultShards ShardedCache 默认的分片数
+const defaultShards = 64
+
+// Hasher 计算key 的hash, ShardedCache 用它选择分片
+type Hasher[K comparable] func(k K) uint64
+
+// ShardedOption ShardedCache 的选项
+type ShardedOption[K comparable, V any] func(*ShardedCache[K, V])
+
+// WithShards 设置分片数
+func WithShards[K comparable, V any](n int) ShardedOption[K, V] {
+	return func(sc *ShardedCache[K, V]) {
+		sc.n = n
+	}
+}
+
+// WithHasher 设置计算key hash 的函数, 默认使用 maphash
+func WithHasher[K comparable, V any](h Hasher[K]) ShardedOption[K, V] {
+	return func(sc *ShardedCache[K, V]) {
+		sc.hasher = h
+	}
+}
+
+// WithShardedTTL 设置超时
+func WithShardedTTL[K comparable, V any](ttl time.Duration) ShardedOption[K, V] {
+	return func(sc *ShardedCache[K, V]) {
+		sc.ttl = ttl
+	}
+}
+
+// WithShardedName 设置cache名称
+func WithShardedName[K comparable, V any](name string) ShardedOption[K, V] {
+	return func(sc *ShardedCache[K, V]) {
+		sc.name = name
+	}
+}
+
+// WithShardedManager 设置cache 注册到的 CacheManager, 默认使用 CacheManagerFactory 返回的
+func WithShardedManager[K comparable, V any](cm CacheManager) ShardedOption[K, V] {
+	return func(sc *ShardedCache[K, V]) {
+		sc.manager = cm
+	}
+}
+
+// shard 是ShardedCache 的一个分片
+type shard[K comparable] struct {
+	mu sync.RWMutex
+	m  map[K]*wrap
+}
+
+// ShardedCache 是按key 的hash 分片、每个分片一把锁的cache, 适合写多的场景
+type ShardedCache[K comparable, V any] struct {
+	n          int
+	shards     []*shard[K]
+	hasher     Hasher[K]
+	ttl        time.Duration
+	name       string
+	manager    CacheManager
+	registered CacheManager
+}
+
+// NewShardedCache 创建分片cache
+func NewShardedCache[K comparable, V any](opts ...ShardedOption[K, V]) *ShardedCache[K, V] {
+	sc := &ShardedCache[K, V]{
+		n:    defaultShards,
+		ttl:  defaultTTL,
+		name: uuid.NewString(),
+	}
+	for i := range opts {
+		opts[i](sc)
+	}
+	if sc.n <= 0 {
+		sc.n = 1
+	}
+	if sc.hasher == nil {
+		seed := maphash.MakeSeed()
+		sc.hasher = func(k K) uint64 {
+			return maphash.Comparable(seed, k)
+		}
+	}
+	sc.shards = make([]*shard[K], sc.n)
+	for i := range sc.shards {
+		sc.shards[i] = &shard[K]{m: make(map[K]*wrap)}
+	}
+	if sc.ttl != defaultTTL {
+		sc.name, sc.registered, _ = registerTo(sc.manager, sc.name, sc)
+	}
+	return sc
+}
+
+func (sc *ShardedCache[K, V]) shard(k K) *shard[K] {
+	return sc.shards[sc.hasher(k)%uint64(sc.n)]
+}
+
+// Name return name of cache
+func (sc *ShardedCache[K, V]) Name() string {
+	return sc.name
+}
+
+// Set 设置k，v
+func (sc *ShardedCache[K, V]) Set(k K, v V) {
+	wp := newWrap(v, sc.ttl, time.Now())
+	s := sc.shard(k)
+	s.mu.Lock()
+	s.m[k] = wp
+	s.mu.Unlock()
+}
+
+// Get 根据key获得value 超时或者空第二个返回值为false，否则返回true
+func (sc *ShardedCache[K, V]) Get(k K) (V, bool) {
+	s := sc.shard(k)
+	s.mu.RLock()
+	wp, ok := s.m[k]
+	s.mu.RUnlock()
+	if !ok || !wp.alive(time.Now()) {
+		return *new(V), false
+	}
+	return wp.v.(V), true
+}
+
+// Del 根据key 删除 cache
+func (sc *ShardedCache[K, V]) Del(k K) {
+	s := sc.shard(k)
+	s.mu.Lock()
+	delete(s.m, k)
+	s.mu.Unlock()
+}
+
+// Remove 根据key 删除 cache
+func (sc *ShardedCache[K, V]) Remove(k ...K) {
+	for i := range k {
+		sc.Del(k[i])
+	}
+}
+
+// Has 是否包含
+func (sc *ShardedCache[K, V]) Has(k ...K) bool {
+	for i := range k {
+		if _, ok := sc.Get(k[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAny 是否包含任何一个键
+func (sc *ShardedCache[K, V]) HasAny(k ...K) bool {
+	for i := range k {
+		if _, ok := sc.Get(k[i]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Len 返回cache 长度
+func (sc *ShardedCache[K, V]) Len() int {
+	now := time.Now()
+	n := 0
+	for _, s := range sc.shards {
+		s.mu.RLock()
+		for _, wp := range s.m {
+			if wp.alive(now) {
+				n++
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Size 返回cache 长度
+func (sc *ShardedCache[K, V]) Size() int {
+	return sc.Len()
+}
+
+// IsEmpty 是否为空
+func (sc *ShardedCache[K, V]) IsEmpty() bool {
+	return sc.Len() == 0
+}
+
+// Clear 清空cache
+func (sc *ShardedCache[K, V]) Clear() {
+	for _, s := range sc.shards {
+		s.mu.Lock()
+		s.m = make(map[K]*wrap)
+		s.mu.Unlock()
+	}
+}
+
+// Range 遍历cache, 遍历时不持有分片的锁, fn 中可以修改cache
+func (sc *ShardedCache[K, V]) Range(fn func(k K, v V) bool) {
+	for _, s := range sc.shards {
+		now := time.Now()
+		s.mu.RLock()
+		ks := make([]K, 0, len(s.m))
+		vs := make([]V, 0, len(s.m))
+		for k, wp := range s.m {
+			if wp.alive(now) {
+				ks = append(ks, k)
+				vs = append(vs, wp.v.(V))
+			}
+		}
+		s.mu.RUnlock()
+		for i := range ks {
+			if !fn(ks[i], vs[i]) {
+				return
+			}
+		}
+	}
+}
+
+// List func list k and list v
+func (sc *ShardedCache[K, V]) List() ([]K, []V) {
+	ks := make([]K, 0)
+	vs := make([]V, 0)
+	sc.Range(func(k K, v V) bool {
+		ks = append(ks, k)
+		vs = append(vs, v)
+		return true
+	})
+	return ks, vs
+}
+
+// ListKey func list k
+func (sc *ShardedCache[K, V]) ListKey() []K {
+	ks, _ := sc.List()
+	return ks
+}
+
+// ListValue func list v
+func (sc *ShardedCache[K, V]) ListValue() []V {
+	_, vs := sc.List()
+	return vs
+}
+
+// Merge 合并cache
+func (sc *ShardedCache[K, V]) Merge(s *Cache[K, V]) {
+	s.Range(func(k K, v V) bool {
+		sc.Set(k, v)
+		return true
+	})
+}
+
+// Clean 会被cache manager 定期调用删除过期的元素
+func (sc *ShardedCache[K, V]) Clean() {
+	for _, s := range sc.shards {
+		now := time.Now()
+		s.mu.Lock()
+		for k, wp := range s.m {
+			if !wp.alive(now) {
+				delete(s.m, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedCache(t *testing.T) {
+	c := NewShardedCache(WithShards[string, int](4))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Set(`c`, 3)
+	v, ok := c.Get(`b`)
+	require.True(t, ok)
+	require.Equal(t, 2, v)
+	require.True(t, c.Has(`a`, `b`, `c`))
+	require.True(t, c.HasAny(`x`, `c`))
+	c.Remove(`a`)
+	ks := c.ListKey()
+	sort.Strings(ks)
+	require.Equal(t, []string{`b`, `c`}, ks)
+	c.Merge(NewCacheInits(map[string]int{`d`: 4}))
+	require.Equal(t, 3, c.Size())
+	c.Clear()
+	require.True(t, c.IsEmpty())
+}
+
+func TestShardedCacheTTL(t *testing.T) {
+	c := NewShardedCache(
+		WithShardedTTL[int, int](time.Millisecond*20),
+		WithHasher[int, int](func(k int) uint64 { return uint64(k) }),
+	)
+	c.Set(1, 1)
+	time.Sleep(time.Millisecond * 30)
+	_, ok := c.Get(1)
+	require.False(t, ok)
+	c.Clean()
+	require.Empty(t, c.shards[1].m)
+}
+
+const benchKeys = 1 << 12
+
+// benchMixed 每10次操作里有2次写
+func benchMixed(b *testing.B, set func(k string, v int), get func(k string) (int, bool)) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		set(keys[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := keys[i%benchKeys]
+			if i%10 < 2 {
+				set(k, i)
+			} else {
+				get(k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheMixed(b *testing.B) {
+	c := NewCache[string, int]()
+	benchMixed(b, c.Set, c.Get)
+}
+
+func BenchmarkShardedCacheMixed(b *testing.B) {
+	c := NewShardedCache[string, int]()
+	benchMixed(b, c.Set, c.Get)
+}
+
+// benchWriteHeavy 每10次操作里有8次写
+func benchWriteHeavy(b *testing.B, set func(k string, v int), del func(k string)) {
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := strconv.Itoa(i % benchKeys)
+			if i%10 < 8 {
+				set(k, i)
+			} else {
+				del(k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheWriteHeavy(b *testing.B) {
+	c := NewCache[string, int]()
+	benchWriteHeavy(b, c.Set, c.Del)
+}
+
+func BenchmarkShardedCacheWriteHeavy(b *testing.B) {
+	c := NewShardedCache[string, int]()
+	benchWriteHeavy(b, c.Set, c.Del)
+}
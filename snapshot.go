@@ -0,0 +1,235 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoPersistence 是没有设置 WithPersistence 的cache 调用 Save 时返回的错误
+var ErrNoPersistence = errors.New(`cache has no persistence path`)
+
+// snapshotVersion 是快照格式的版本
+const snapshotVersion = 1
+
+// snapshotHeader 是快照开头的记录
+type snapshotHeader struct {
+	Version int
+	Time    int64 // 写快照的时间, UnixNano
+}
+
+// snapshotEntry 是快照中的一个元素, TTL 是写快照时剩余的存活时间
+type snapshotEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	TTL   time.Duration
+}
+
+// Snapshot 把所有没超时的元素和剩余的存活时间写入w
+// 设置了空闲超时时保存的是剩余的最长存活时间, 恢复后空闲时间重新计算
+func (c *Cache[K, V]) Snapshot(w io.Writer) error {
+	enc := c.codec.NewEncoder(w)
+	now := c.clock.Now()
+	if e := enc.Encode(snapshotHeader{Version: snapshotVersion, Time: now.UnixNano()}); e != nil {
+		return e
+	}
+	var err error
+	c.entries().Range(func(k, v any) bool {
+		wp := v.(*wrap)
+		if !wp.alive(now) {
+			return true
+		}
+		deadline := wp.timeout
+		if !wp.hard.IsZero() {
+			deadline = wp.hard
+		}
+		err = enc.Encode(snapshotEntry[K, V]{Key: k.(K), Value: wp.v.(V), TTL: deadline.Sub(now)})
+		return err == nil
+	})
+	return err
+}
+
+// Restore 从r 读取 Snapshot 写入的元素, 每个元素使用快照中剩余的存活时间
+// 已有的元素不会被清空, 同一个key 会被覆盖
+func (c *Cache[K, V]) Restore(r io.Reader) error {
+	return c.restore(r, time.Time{})
+}
+
+// restore 读取快照, now 不为零值时减去写快照之后经过的时间, 恢复成写快照时的超时时间
+func (c *Cache[K, V]) restore(r io.Reader, now time.Time) error {
+	return readSnapshot(c.codec, r, now, func(e snapshotEntry[K, V]) {
+		if e.TTL > 0 {
+			c.SetWithTTL(e.Key, e.Value, e.TTL)
+		}
+	})
+}
+
+// readSnapshot 读取快照, 对每个元素调用fn, now 不为零值时 TTL 减去写快照之后经过的时间
+func readSnapshot[K comparable, V any](codec Codec, r io.Reader, now time.Time, fn func(e snapshotEntry[K, V])) error {
+	dec := codec.NewDecoder(r)
+	h := snapshotHeader{}
+	if e := dec.Decode(&h); e != nil {
+		return e
+	}
+	if h.Version != snapshotVersion {
+		return fmt.Errorf(`unsupported snapshot version %v`, h.Version)
+	}
+	var elapsed time.Duration
+	if !now.IsZero() && h.Time != 0 {
+		elapsed = now.Sub(time.Unix(0, h.Time))
+	}
+	for {
+		e := snapshotEntry[K, V]{}
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e.TTL -= elapsed
+		fn(e)
+	}
+}
+
+// Snapshot 把set 的元素和剩余的存活时间写入w
+func (s *Set[K]) Snapshot(w io.Writer) error {
+	return s.inner.Snapshot(w)
+}
+
+// Restore 从r 读取 Snapshot 写入的元素
+func (s *Set[K]) Restore(r io.Reader) error {
+	return s.inner.Restore(r)
+}
+
+// Snapshot 把主表的元素和剩余的存活时间写入w, 索引在 Restore 时重建
+func (ix *Indexer[T]) Snapshot(w io.Writer) error {
+	if ix.main == nil {
+		ix.main = ix.newMain()
+	}
+	return ix.main.Snapshot(w)
+}
+
+// Restore 从r 读取 Snapshot 写入的元素并重建索引
+func (ix *Indexer[T]) Restore(r io.Reader) error {
+	if ix.main == nil {
+		ix.main = ix.newMain()
+	}
+	return readSnapshot(ix.main.codec, r, time.Time{}, func(e snapshotEntry[string, T]) {
+		if e.TTL > 0 {
+			ix.Set(e.Value)
+			ix.main.Expire(e.Key, e.TTL)
+		}
+	})
+}
+
+// WithPersistence 创建cache 时从path 加载快照, 之后每隔interval 把快照写入path
+// 写入时先写临时文件再重命名, interval <= 0 时只在调用 Save 时写入
+// 加载时减去写快照之后经过的时间, 元素仍然按原来的超时时间过期
+func WithPersistence[K comparable, V any](path string, interval time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.persistPath = path
+		cache.persistInterval = interval
+	}
+}
+
+// Save 立即把快照写入 WithPersistence 设置的文件
+func (c *Cache[K, V]) Save() error {
+	if c.persistPath == `` {
+		return ErrNoPersistence
+	}
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+	c.persistErr = writeFileAtomic(c.persistPath, c.Snapshot)
+	return c.persistErr
+}
+
+// PersistError 返回最近一次加载或写入快照文件的错误
+func (c *Cache[K, V]) PersistError() error {
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+	return c.persistErr
+}
+
+// startPersistence 加载快照文件并开始定期写入
+func (c *Cache[K, V]) startPersistence() {
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+	c.persistErr = c.load()
+	if c.persistInterval > 0 {
+		c.persistTimer = c.clock.AfterFunc(c.persistInterval, c.persistTick)
+	}
+}
+
+// persistTick 写入快照后等待下一个间隔, 已经停止时不再写入
+func (c *Cache[K, V]) persistTick() {
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+	if c.persistTimer == nil {
+		return
+	}
+	c.persistErr = writeFileAtomic(c.persistPath, c.Snapshot)
+	c.persistTimer = c.clock.AfterFunc(c.persistInterval, c.persistTick)
+}
+
+// stopPersistence 停止定期写入
+func (c *Cache[K, V]) stopPersistence() {
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+	if c.persistTimer != nil {
+		c.persistTimer.Stop()
+		c.persistTimer = nil
+	}
+}
+
+// load 从快照文件恢复, 文件不存在时什么也不做
+func (c *Cache[K, V]) load() error {
+	f, err := os.Open(c.persistPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.restore(bufio.NewReader(f), c.clock.Now())
+}
+
+// writeFileAtomic 把write 写出的内容写入临时文件后重命名为path
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+`.tmp*`)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	bw := bufio.NewWriter(f)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type city struct {
+	Name    string
+	Country string
+}
+
+func (ct *city) Indexes() map[string]IndexFunc {
+	return map[string]IndexFunc{
+		IndexByCountry: func(indexed any) []string {
+			return []string{indexed.(*city).Country}
+		},
+	}
+}
+
+func (ct *city) ID() string {
+	return ct.Name
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	for name, codec := range map[string]Codec{`gob`: GobCodec, `json`: JSONCodec} {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache(WithCodec[string, int](codec))
+			c.SetWithTTL(`a`, 1, time.Hour)
+			c.SetWithTTL(`b`, 2, time.Millisecond)
+			c.Set(`c`, 3)
+			time.Sleep(time.Millisecond * 5)
+			buf := bytes.Buffer{}
+			require.NoError(t, c.Snapshot(&buf))
+
+			r := NewCache(WithCodec[string, int](codec))
+			require.NoError(t, r.Restore(&buf))
+			ks := r.ListKey()
+			sort.Strings(ks)
+			require.Equal(t, []string{`a`, `c`}, ks)
+			ttl, ok := r.TTL(`a`)
+			require.True(t, ok)
+			require.Greater(t, ttl, time.Minute*59)
+			require.LessOrEqual(t, ttl, time.Hour)
+			v, _ := r.Get(`c`)
+			require.Equal(t, 3, v)
+		})
+	}
+}
+
+func TestSnapshotSetIndexer(t *testing.T) {
+	s := NewSetInits([]string{`a`, `b`})
+	buf := bytes.Buffer{}
+	require.NoError(t, s.Snapshot(&buf))
+	s2 := NewSet[string]()
+	require.NoError(t, s2.Restore(&buf))
+	require.True(t, s2.IsEqual(s))
+
+	ix := NewIndexer[*city]()
+	ix.Set(&city{Name: `beijing`, Country: `China`})
+	ix.Set(&city{Name: `shanghai`, Country: `China`})
+	ix.Set(&city{Name: `boston`, Country: `America`})
+	buf.Reset()
+	require.NoError(t, ix.Snapshot(&buf))
+	ix2 := NewIndexer[*city]()
+	require.NoError(t, ix2.Restore(&buf))
+	require.Equal(t, 3, ix2.Len())
+	require.Len(t, ix2.Search(IndexByCountry, `China`).InvokeAll(), 2)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.snap`)
+	c := NewCache(WithPersistence[string, int](path, 0))
+	require.NoError(t, c.PersistError())
+	c.Set(`a`, 1)
+	require.NoError(t, c.Save())
+	require.ErrorIs(t, NewCache[string, int]().Save(), ErrNoPersistence)
+
+	r := NewCache(WithPersistence[string, int](path, 0))
+	require.NoError(t, r.PersistError())
+	v, ok := r.Get(`a`)
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	matches, _ := filepath.Glob(path + `.tmp*`)
+	require.Empty(t, matches)
+
+	ix := NewIndexer[*city](WithPersistence[string, *city](path+`.ix`, 0))
+	ix.Set(&city{Name: `boston`, Country: `America`})
+	require.NoError(t, ix.main.Save())
+	ix2 := NewIndexer[*city](WithPersistence[string, *city](path+`.ix`, 0))
+	require.Equal(t, `boston`, ix2.Search(IndexByCountry, `America`).InvokeOne().Name)
+}
+
+func TestPersistenceInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.snap`)
+	c := NewCache(WithPersistence[string, int](path, time.Millisecond*10))
+	t.Cleanup(c.stopPersistence)
+	c.Set(`a`, 1)
+	require.Eventually(t, func() bool {
+		r := NewCache(WithPersistence[string, int](path, 0))
+		return r.Has(`a`)
+	}, time.Second, time.Millisecond*10)
+}
@@ -0,0 +1,110 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats 是cache 的统计信息
+type Stats struct {
+	Hits        uint64        // Get 命中次数
+	Misses      uint64        // Get 未命中次数
+	Sets        uint64        // Set 次数
+	Deletes     uint64        // 被 Del 删除的元素数
+	Expirations uint64        // 超时被删除的元素数
+	Evictions   uint64        // 超过容量被淘汰的元素数
+	Loads       uint64        // Loader 调用次数
+	LoadErrors  uint64        // Loader 返回错误的次数
+	LoadTime    time.Duration // Loader 总耗时
+	Size        int           // 当前元素个数
+}
+
+// HitRatio 返回命中率, 没有 Get 时返回0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AvgLoadTime 返回 Loader 的平均耗时
+func (s Stats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+// statsCounter 用原子计数器收集统计信息
+type statsCounter struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	sets        atomic.Uint64
+	deletes     atomic.Uint64
+	expirations atomic.Uint64
+	evictions   atomic.Uint64
+	loads       atomic.Uint64
+	loadErrors  atomic.Uint64
+	loadTime    atomic.Int64
+}
+
+// recordLoad 记录一次 Loader 调用
+func (sc *statsCounter) recordLoad(start time.Time, e error) {
+	sc.loads.Add(1)
+	sc.loadTime.Add(int64(time.Since(start)))
+	if e != nil {
+		sc.loadErrors.Add(1)
+	}
+}
+
+func (sc *statsCounter) reset() {
+	sc.hits.Store(0)
+	sc.misses.Store(0)
+	sc.sets.Store(0)
+	sc.deletes.Store(0)
+	sc.expirations.Store(0)
+	sc.evictions.Store(0)
+	sc.loads.Store(0)
+	sc.loadErrors.Store(0)
+	sc.loadTime.Store(0)
+}
+
+// Stats 返回cache 的统计信息
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        c.stats.hits.Load(),
+		Misses:      c.stats.misses.Load(),
+		Sets:        c.stats.sets.Load(),
+		Deletes:     c.stats.deletes.Load(),
+		Expirations: c.stats.expirations.Load(),
+		Evictions:   c.stats.evictions.Load(),
+		Loads:       c.stats.loads.Load(),
+		LoadErrors:  c.stats.loadErrors.Load(),
+		LoadTime:    time.Duration(c.stats.loadTime.Load()),
+		Size:        c.Len(),
+	}
+}
+
+// ResetStats 清零统计信息
+func (c *Cache[K, V]) ResetStats() {
+	c.stats.reset()
+}
+
+// Stats 返回Indexer 主表的统计信息
+func (ix *Indexer[T]) Stats() Stats {
+	return ix.main.Stats()
+}
+
+// ResetStats 清零Indexer 主表的统计信息
+func (ix *Indexer[T]) ResetStats() {
+	ix.main.ResetStats()
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheStats(t *testing.T) {
+	c := NewCache(WithTTL[string, int](time.Millisecond*20), WithMaxEntries[string, int](2))
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Get(`a`)
+	c.Get(`x`)
+	c.Set(`c`, 3)
+	c.Del(`c`)
+	s := c.Stats()
+	require.Equal(t, uint64(1), s.Hits)
+	require.Equal(t, uint64(1), s.Misses)
+	require.Equal(t, 0.5, s.HitRatio())
+	require.Equal(t, uint64(3), s.Sets)
+	require.Equal(t, uint64(1), s.Deletes)
+	require.Equal(t, uint64(1), s.Evictions)
+	require.Equal(t, 1, s.Size)
+
+	time.Sleep(time.Millisecond * 30)
+	c.Clean()
+	s = c.Stats()
+	require.Equal(t, uint64(1), s.Expirations)
+	require.Equal(t, 0, s.Size)
+
+	c.ResetStats()
+	require.Equal(t, Stats{}, c.Stats())
+}
+
+func TestCacheStatsLoad(t *testing.T) {
+	c := NewCache(WithLoader(func(ctx context.Context, k string) (int, error) {
+		if k == `bad` {
+			return 0, errors.New(`bad key`)
+		}
+		return 1, nil
+	}))
+	_, _ = c.GetOrLoad(context.Background(), `a`)
+	_, _ = c.GetOrLoad(context.Background(), `bad`)
+	s := c.Stats()
+	require.Equal(t, uint64(2), s.Loads)
+	require.Equal(t, uint64(1), s.LoadErrors)
+	require.Equal(t, uint64(2), s.Misses)
+	_, _ = c.GetOrLoad(context.Background(), `a`)
+	require.Equal(t, uint64(1), c.Stats().Hits)
+}
+
+func TestIndexerStats(t *testing.T) {
+	ix := NewIndexer[*Person]()
+	ix.Set(p1)
+	ix.Get(p1.ID())
+	s := ix.Stats()
+	require.Equal(t, 1, s.Size)
+	require.Equal(t, uint64(1), s.Sets)
+}
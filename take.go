@@ -0,0 +1,60 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+// GetAndDelete 取出并删除key, 并发调用时只有一个调用方能取到值
+func (c *Cache[K, V]) GetAndDelete(k K) (V, bool) {
+	for {
+		wp, alive := c.current(k)
+		if wp == nil {
+			return *new(V), false
+		}
+		if !alive {
+			c.delExpired(k, wp)
+			return *new(V), false
+		}
+		if c.mutate(k, wp, nil) {
+			return wp.v.(V), true
+		}
+	}
+}
+
+// TakeAll 一次换成空的存储, 取出并删除原来所有没超时的元素, 每个元素只会被一个调用方取到
+// 取出的元素回调的原因是 ReasonTaken, 已经超时的元素是 ReasonExpired
+func (c *Cache[K, V]) TakeAll() map[K]V {
+	old := c.drain()
+	now := c.clock.Now()
+	res := make(map[K]V)
+	old.Range(func(k, v any) bool {
+		vk, wp := k.(K), v.(*wrap)
+		if !wp.alive(now) {
+			c.stats.expirations.Add(1)
+			c.notifyEvict(removed[K]{k: vk, wp: wp, reason: ReasonExpired})
+			return true
+		}
+		res[vk] = wp.v.(V)
+		c.stats.deletes.Add(1)
+		c.notifyEvict(removed[K]{k: vk, wp: wp, reason: ReasonTaken})
+		return true
+	})
+	return res
+}
+
+// PopExpired 删除并返回所有已经超时的元素
+func (c *Cache[K, V]) PopExpired() map[K]V {
+	res := make(map[K]V)
+	c.entries().Range(func(k, v any) bool {
+		vk, wp := k.(K), v.(*wrap)
+		if !wp.alive(c.clock.Now()) && c.delExpired(vk, wp) {
+			res[vk] = wp.v.(V)
+		}
+		return true
+	})
+	return res
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAndDelete(t *testing.T) {
+	c := NewCache[string, int]()
+	c.Set(`a`, 1)
+	v, ok := c.GetAndDelete(`a`)
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+	_, ok = c.GetAndDelete(`a`)
+	require.False(t, ok)
+	require.True(t, c.IsEmpty())
+}
+
+func TestTakeAll(t *testing.T) {
+	c := NewCacheInits(map[string]int{`a`: 1, `b`: 2})
+	require.Equal(t, map[string]int{`a`: 1, `b`: 2}, c.TakeAll())
+	require.True(t, c.IsEmpty())
+	require.Empty(t, c.TakeAll())
+}
+
+func TestTakeAllEvents(t *testing.T) {
+	reasons := map[string]Reason{}
+	c := NewCache(
+		WithTTL[string, int](time.Hour),
+		WithMaxCost[string, int](100),
+		WithWeigher(func(k string, v int) int64 { return int64(v) }),
+		WithOnEvict(func(k string, v int, r Reason) {
+			reasons[k] = r
+		}),
+	)
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.SetWithTTL(`c`, 3, time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	require.Equal(t, map[string]int{`a`: 1, `b`: 2}, c.TakeAll())
+	require.Equal(t, map[string]Reason{`a`: ReasonTaken, `b`: ReasonTaken, `c`: ReasonExpired}, reasons)
+	require.Equal(t, int64(0), c.Cost())
+	require.Equal(t, 0, c.Len())
+	c.Set(`d`, 4)
+	require.Equal(t, int64(4), c.Cost())
+	require.Equal(t, `taken`, ReasonTaken.String())
+}
+
+func TestTakeAllConcurrentSet(t *testing.T) {
+	c := NewCache[int, int]()
+	n := 10000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			c.Set(i, i)
+		}
+	}()
+	got := map[int]int{}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for k, v := range c.TakeAll() {
+			got[k] = v
+		}
+	}
+	for k, v := range c.TakeAll() {
+		got[k] = v
+	}
+	require.Len(t, got, n)
+	require.Equal(t, 0, c.Len())
+	require.Equal(t, int64(0), c.count.Load())
+}
+
+func TestPopExpired(t *testing.T) {
+	c := NewCache(WithTTL[string, int](time.Hour))
+	c.Set(`a`, 1)
+	c.SetWithTTL(`b`, 2, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20)
+	require.Equal(t, map[string]int{`b`: 2}, c.PopExpired())
+	require.Equal(t, 1, c.Len())
+}
+
+func TestPopConcurrent(t *testing.T) {
+	n := 1000
+	inits := make([]int, n)
+	for i := range inits {
+		inits[i] = i
+	}
+	s := NewSetInits(inits)
+	ix := NewIndexer[*Person]()
+	ix.Add(p1, p2, p3, p4, p5, p6, p7)
+	mu := sync.Mutex{}
+	got := map[int]int{}
+	gotP := map[string]int{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				x := s.Pop()
+				p := ix.Pop()
+				mu.Lock()
+				got[x]++
+				if p != nil {
+					gotP[p.ID()]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 1; i < n; i++ {
+		require.Equal(t, 1, got[i])
+	}
+	require.Len(t, gotP, 7)
+	for _, cnt := range gotP {
+		require.Equal(t, 1, cnt)
+	}
+	require.True(t, s.IsEmpty())
+	require.True(t, ix.IsEmpty())
+}
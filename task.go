@@ -0,0 +1,240 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weapons97/cache/wait"
+)
+
+// ErrDuplicateTask 是 Schedule 的任务名称已经存在时返回的错误
+var ErrDuplicateTask = errors.New(`duplicate task name`)
+
+// TaskFunc 是 CacheManager 定期执行的任务, ctx 在 CacheManager 停止或任务被取消时结束
+type TaskFunc func(ctx context.Context) error
+
+// TaskOption 是任务的选项
+type TaskOption func(*task)
+
+// WithTaskJitter 每次等待的间隔随机增加 [0, factor*间隔) 的时间, 避免多个任务同时执行
+func WithTaskJitter(factor float64) TaskOption {
+	return func(t *task) {
+		t.jitter = factor
+	}
+}
+
+// WithTaskBackoff 任务出错后等待的间隔每次翻倍, 最多到max, 成功一次后恢复
+func WithTaskBackoff(max time.Duration) TaskOption {
+	return func(t *task) {
+		t.maxBackoff = max
+	}
+}
+
+// TaskStatus 是任务的运行状态
+type TaskStatus struct {
+	Name                string
+	Interval            time.Duration
+	Running             bool          // 是否正在执行
+	Runs                uint64        // 执行次数
+	Failures            uint64        // 出错或panic 的次数
+	ConsecutiveFailures int           // 连续出错的次数, 成功后清零
+	LastRun             time.Time     // 上次开始执行的时间
+	LastDuration        time.Duration // 上次执行的耗时
+	LastError           error         // 上次执行的错误, panic 也会变成错误
+	NextRun             time.Time     // 下次执行的时间, 没有运行时为零值
+}
+
+// task 是一个注册的定期任务
+type task struct {
+	fn         TaskFunc
+	clock      Clock
+	jitter     float64
+	maxBackoff time.Duration
+	cancel     context.CancelFunc
+
+	mu     sync.Mutex // 保护 status
+	status TaskStatus
+}
+
+// run 执行一次任务并记录状态
+func (t *task) run(ctx context.Context) {
+	start := t.clock.Now()
+	t.mu.Lock()
+	t.status.Running = true
+	t.status.LastRun = start
+	t.mu.Unlock()
+	err := t.call(ctx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Running = false
+	t.status.Runs++
+	t.status.LastDuration = t.clock.Now().Sub(start)
+	t.status.LastError = err
+	if err != nil {
+		t.status.Failures++
+		t.status.ConsecutiveFailures++
+	} else {
+		t.status.ConsecutiveFailures = 0
+	}
+}
+
+// call 执行任务函数, 把panic 转成错误
+func (t *task) call(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf(`task %v panic: %v`, t.status.Name, r)
+		}
+	}()
+	return t.fn(ctx)
+}
+
+// next 返回到下一次执行要等待的时间, 出错时按 WithTaskBackoff 增加间隔
+func (t *task) next() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := t.status.Interval
+	if t.maxBackoff > d {
+		for i := 0; i < t.status.ConsecutiveFailures && d < t.maxBackoff; i++ {
+			d *= 2
+		}
+		d = min(d, t.maxBackoff)
+	}
+	if t.jitter > 0 {
+		d = wait.Jitter(d, t.jitter)
+	}
+	t.status.NextRun = t.clock.Now().Add(d)
+	return d
+}
+
+// snapshot 返回任务的状态
+func (t *task) snapshot() TaskStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// Schedule 注册一个每隔interval 执行一次的任务, CacheManager 运行时立即执行一次
+// 任务出错或panic 不会影响后续执行, 名称重复时返回 ErrDuplicateTask, interval <= 0 时返回错误
+// wait.BackoffUntil 只能使用 *time.Timer, 任务自己用 CacheManager 的 Clock 计时, 测试时可以用假的时钟驱动
+func (cm *cacheManager) Schedule(name string, interval time.Duration, fn TaskFunc, opts ...TaskOption) error {
+	if interval <= 0 {
+		return fmt.Errorf(`task %v: interval must be positive, got %v`, name, interval)
+	}
+	t := &task{fn: fn, clock: cm.clock}
+	t.status.Name = name
+	t.status.Interval = interval
+	for i := range opts {
+		opts[i](t)
+	}
+	cm.lifeMu.Lock()
+	defer cm.lifeMu.Unlock()
+	if _, ok := cm.tasks[name]; ok {
+		return fmt.Errorf(`%w: %v`, ErrDuplicateTask, name)
+	}
+	cm.tasks[name] = t
+	if cm.ctx != nil {
+		cm.startTaskLocked(t)
+	}
+	return nil
+}
+
+// Unschedule 取消任务, 任务不存在时返回false, 正在执行的任务会收到ctx 结束
+func (cm *cacheManager) Unschedule(name string) bool {
+	cm.lifeMu.Lock()
+	defer cm.lifeMu.Unlock()
+	t, ok := cm.tasks[name]
+	if !ok {
+		return false
+	}
+	delete(cm.tasks, name)
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return true
+}
+
+// TaskStatus 返回任务的状态
+func (cm *cacheManager) TaskStatus(name string) (TaskStatus, bool) {
+	cm.lifeMu.Lock()
+	t, ok := cm.tasks[name]
+	cm.lifeMu.Unlock()
+	if !ok {
+		return TaskStatus{}, false
+	}
+	return t.snapshot(), true
+}
+
+// ListTasks 按名称顺序返回所有任务的状态
+func (cm *cacheManager) ListTasks() []TaskStatus {
+	cm.lifeMu.Lock()
+	ts := make([]*task, 0, len(cm.tasks))
+	for _, t := range cm.tasks {
+		ts = append(ts, t)
+	}
+	cm.lifeMu.Unlock()
+	res := make([]TaskStatus, 0, len(ts))
+	for _, t := range ts {
+		res = append(res, t.snapshot())
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// startTaskLocked 在单独的goroutine 中运行任务直到 CacheManager 停止或任务被取消, 调用方需持有 cm.lifeMu
+func (cm *cacheManager) startTaskLocked(t *task) {
+	ctx, cancel := context.WithCancel(cm.ctx)
+	t.cancel = cancel
+	cm.taskWG.Add(1)
+	go func() {
+		defer cm.taskWG.Done()
+		t.loop(ctx)
+		t.mu.Lock()
+		t.status.NextRun = time.Time{}
+		t.mu.Unlock()
+	}()
+}
+
+// loop 执行任务直到ctx 结束, 每次执行完后用 Clock 的定时器等待下一次
+func (t *task) loop(ctx context.Context) {
+	wake := make(chan struct{}, 1)
+	var timer Timer
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		t.run(ctx)
+		d := t.next()
+		if timer == nil {
+			timer = t.clock.AfterFunc(d, func() {
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			})
+		} else {
+			timer.Reset(d)
+		}
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-wake:
+		}
+	}
+}
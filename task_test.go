@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskNext(t *testing.T) {
+	tk := &task{clock: RealClock, maxBackoff: time.Second}
+	tk.status.Interval = time.Millisecond * 100
+	require.Equal(t, time.Millisecond*100, tk.next())
+	tk.status.ConsecutiveFailures = 2
+	require.Equal(t, time.Millisecond*400, tk.next())
+	tk.status.ConsecutiveFailures = 10
+	require.Equal(t, time.Second, tk.next())
+
+	tk = &task{clock: RealClock, jitter: 0.5}
+	tk.status.Interval = time.Millisecond * 100
+	for i := 0; i < 10; i++ {
+		d := tk.next()
+		require.GreaterOrEqual(t, d, time.Millisecond*100)
+		require.Less(t, d, time.Millisecond*150)
+	}
+}
@@ -0,0 +1,104 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// Attribute 是span 的属性
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr 创建一个 Attribute
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span 是一次被追踪的操作
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	End()
+}
+
+// Tracer 追踪cache 的操作, 可以用来对接OpenTelemetry 等追踪系统
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// NoopTracer 什么都不做的 Tracer, 是cache 默认的 Tracer
+type NoopTracer struct{}
+
+// Start 返回什么都不做的 Span
+func (NoopTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// noopSpan 什么都不做的 Span
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+
+func (noopSpan) End() {}
+
+// WithTracer 设置cache 的 Tracer, Indexer 使用主表的 Tracer
+func WithTracer[K comparable, V any](t Tracer) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.tracer = t
+	}
+}
+
+// span 包装 Span 并记录开始时间, nil 表示不追踪
+type span struct {
+	s     Span
+	start time.Time
+}
+
+// traced 是否需要追踪, 使用 NoopTracer 时为false
+func (c *Cache[K, V]) traced() bool {
+	if c.tracer == nil {
+		return false
+	}
+	_, noop := c.tracer.(NoopTracer)
+	return !noop
+}
+
+// startSpan 开始追踪对k 的一次操作, 使用 NoopTracer 时返回nil 并且不计算key 的hash
+// 调用方带属性调用 span.end 前需要先判断span 不为nil, 避免不追踪时分配属性
+func (c *Cache[K, V]) startSpan(ctx context.Context, name string, k K, attrs ...Attribute) (context.Context, *span) {
+	if !c.traced() {
+		return ctx, nil
+	}
+	attrs = append(attrs, Attr(`cache.key_hash`, keyHash(k)), Attr(`cache.name`, c.name))
+	ctx, s := c.tracer.Start(ctx, name, attrs...)
+	return ctx, &span{s: s, start: time.Now()}
+}
+
+// end 结束追踪并记录耗时
+func (sp *span) end(attrs ...Attribute) {
+	if sp == nil {
+		return
+	}
+	attrs = append(attrs, Attr(`cache.duration`, time.Since(sp.start)))
+	sp.s.SetAttributes(attrs...)
+	sp.s.End()
+}
+
+// keyHash 返回key 的hash, 避免把key 原文写进追踪系统
+func keyHash(k any) string {
+	h := fnv.New64a()
+	fmt.Fprint(h, k)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoopTracerNoAlloc(t *testing.T) {
+	c := NewCache[int, int]()
+	c.Set(1, 1)
+	allocs := testing.AllocsPerRun(100, func() {
+		c.Get(1)
+		c.Get(2)
+	})
+	require.Zero(t, allocs)
+}
@@ -0,0 +1,100 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"time"
+)
+
+// SetWithTTL 设置k，v 并单独指定超时, 不使用cache 的ttl
+// 没有设置 WithTTL 的cache 不受 CacheManager 管理, 超时的元素在访问时删除
+func (c *Cache[K, V]) SetWithTTL(k K, v V, ttl time.Duration) {
+	c.set(k, v, &wrap{timeout: c.clock.Now().Add(ttl), v: v})
+}
+
+// SetWithDeadline 设置k，v 并在deadline 超时
+func (c *Cache[K, V]) SetWithDeadline(k K, v V, deadline time.Time) {
+	c.set(k, v, &wrap{timeout: deadline, v: v})
+}
+
+// TTL 返回元素剩余的存活时间, 元素不存在或已超时第二个返回值为false
+func (c *Cache[K, V]) TTL(k K) (time.Duration, bool) {
+	x, ok := c.entries().Load(k)
+	if !ok {
+		return 0, false
+	}
+	wp := x.(*wrap)
+	now := c.clock.Now()
+	if !wp.alive(now) {
+		return 0, false
+	}
+	return wp.timeout.Sub(now), true
+}
+
+// Expire 把已存在元素的超时改为从现在开始ttl 后, 元素不存在或已超时返回false
+func (c *Cache[K, V]) Expire(k K, ttl time.Duration) bool {
+	return c.retime(k, c.clock.Now().Add(ttl))
+}
+
+// Persist 让已存在的元素永不超时, 元素不存在或已超时返回false
+func (c *Cache[K, V]) Persist(k K) bool {
+	return c.retime(k, c.clock.Now().Add(Forever))
+}
+
+// retime 修改已存在元素的超时时间
+func (c *Cache[K, V]) retime(k K, timeout time.Time) bool {
+	for {
+		x, ok := c.entries().Load(k)
+		if !ok {
+			return false
+		}
+		old := x.(*wrap)
+		if !old.alive(c.clock.Now()) {
+			return false
+		}
+		nw := &wrap{timeout: timeout, v: old.v, cost: old.cost}
+		if c.idleTimeout > 0 {
+			nw.hard = timeout
+			nw.timeout = minTime(c.clock.Now().Add(c.idleTimeout), timeout)
+		}
+		locking := c.locking()
+		if locking {
+			c.mu.Lock()
+		}
+		ok = c.swapWrap(k, old, nw)
+		if ok {
+			c.logExpire(k, timeout)
+		}
+		if locking {
+			c.mu.Unlock()
+		}
+		if ok {
+			return true
+		}
+	}
+}
+
+// swapWrap 在元素没有被修改时用nw 替换old, 并更新超时记录
+func (c *Cache[K, V]) swapWrap(k K, old, nw *wrap) bool {
+	c.swapMu.RLock()
+	defer c.swapMu.RUnlock()
+	if !c.entries().CompareAndSwap(k, old, nw) {
+		return false
+	}
+	c.account(k, old, nw)
+	return true
+}
+
+// minTime 返回较早的时间
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
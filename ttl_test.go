@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetWithTTL(t *testing.T) {
+	c := NewCache[string, int]()
+	c.SetWithTTL(`a`, 1, time.Millisecond*20)
+	c.SetWithDeadline(`b`, 2, time.Now().Add(time.Hour))
+	c.Set(`c`, 3)
+	ttl, ok := c.TTL(`a`)
+	require.True(t, ok)
+	require.LessOrEqual(t, ttl, time.Millisecond*20)
+	ttl, ok = c.TTL(`b`)
+	require.True(t, ok)
+	require.Greater(t, ttl, time.Minute)
+	time.Sleep(time.Millisecond * 30)
+	require.False(t, c.Has(`a`))
+	require.True(t, c.Has(`b`, `c`))
+	require.Equal(t, 2, c.Len())
+	_, ok = c.TTL(`a`)
+	require.False(t, ok)
+}
+
+func TestExpirePersist(t *testing.T) {
+	c := NewCache(WithTTL[string, int](time.Hour), WithActiveExpiry[string, int]())
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	require.True(t, c.Expire(`a`, time.Millisecond*20))
+	require.False(t, c.Expire(`x`, time.Second))
+	require.True(t, c.Persist(`b`))
+	ttl, _ := c.TTL(`b`)
+	require.Greater(t, ttl, time.Hour*24)
+	require.Eventually(t, func() bool {
+		return c.Len() == 1
+	}, time.Second, time.Millisecond*5)
+	require.True(t, c.Has(`b`))
+	require.False(t, c.Persist(`a`))
+}
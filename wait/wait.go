@@ -1,6 +1,7 @@
 package wait
 
 import (
+	"math/rand/v2"
 	"time"
 
 	"context"
@@ -96,3 +97,11 @@ func NewSimpleBackoffManager(period time.Duration) BackoffManager {
 	bf.duration = period
 	return bf
 }
+
+// Jitter 返回 duration 加上 [0, maxFactor*duration) 之间的随机时间, maxFactor <= 0 时按1.0 计算
+func Jitter(duration time.Duration, maxFactor float64) time.Duration {
+	if maxFactor <= 0.0 {
+		maxFactor = 1.0
+	}
+	return duration + time.Duration(rand.Float64()*maxFactor*float64(duration))
+}
@@ -0,0 +1,280 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// walHeaderSize 是每条记录前的长度和校验和
+const walHeaderSize = 8
+
+// walMaxRecord 是一条记录的最大长度, 超过时认为文件已损坏
+const walMaxRecord = 1 << 30
+
+// walOp 是写日志记录的操作
+type walOp uint8
+
+const (
+	walSet walOp = iota + 1
+	walDel
+	walExpire
+	walClear
+)
+
+// walRecord 是写日志中的一条记录, Deadline 是超时时间的 UnixNano
+type walRecord[K comparable, V any] struct {
+	Op       walOp
+	Key      K
+	Value    V
+	Deadline int64
+}
+
+// WithWriteLog 把 Set Del Expire 等修改追加到path 的写日志, 创建cache 时重放日志恢复数据
+// 日志超过maxSize 字节时把cache 压缩成快照写入 path.snapshot 并清空日志, maxSize <= 0 时不压缩
+// 快照记录了写入的时间, 重启后元素仍然按原来的超时时间过期
+// 每条记录带有长度和校验和, 进程崩溃时写了一半的记录会在重放时丢弃
+func WithWriteLog[K comparable, V any](path string, maxSize int64) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.walPath = path
+		cache.walMaxSize = maxSize
+	}
+}
+
+// writeLog 是追加写的日志文件
+type writeLog struct {
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	maxSize int64
+	codec   Codec
+	buf     bytes.Buffer
+	err     error
+	compact func() error // 把cache 写成快照
+}
+
+// append 追加一条记录, 超过大小时压缩
+// 写入失败时去掉写了一半的记录并停止记录日志, 错误可以通过 WriteLogError 获取
+func (l *writeLog) append(rec any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	l.buf.Reset()
+	l.buf.Write(make([]byte, walHeaderSize))
+	if l.err = l.codec.NewEncoder(&l.buf).Encode(rec); l.err != nil {
+		return
+	}
+	b := l.buf.Bytes()
+	payload := b[walHeaderSize:]
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(payload))
+	if _, err := l.f.Write(b); err != nil {
+		l.err = err
+		l.f.Truncate(l.size)
+		l.f.Close()
+		l.f = nil
+		return
+	}
+	l.size += int64(len(b))
+	if l.maxSize > 0 && l.size > l.maxSize {
+		l.err = l.compactLocked()
+	}
+}
+
+// compactLocked 写快照后清空日志, 调用方需持有 l.mu
+func (l *writeLog) compactLocked() error {
+	if e := l.compact(); e != nil {
+		return e
+	}
+	if e := l.f.Truncate(0); e != nil {
+		return e
+	}
+	l.size = 0
+	_, e := l.f.Seek(0, io.SeekStart)
+	return e
+}
+
+// close 关闭日志文件
+func (l *writeLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	e := l.f.Close()
+	l.f = nil
+	return e
+}
+
+// readWriteLog 依次读出r 中完整且校验通过的记录, 返回最后一条完整记录结束的位置
+// 末尾不完整或校验失败的记录被忽略
+func readWriteLog(r io.Reader, fn func(payload []byte) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var off int64
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, e := io.ReadFull(br, header); e != nil {
+			return off, nil
+		}
+		n := binary.BigEndian.Uint32(header[0:4])
+		if n > walMaxRecord {
+			return off, nil
+		}
+		payload := make([]byte, n)
+		if _, e := io.ReadFull(br, payload); e != nil {
+			return off, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			return off, nil
+		}
+		if e := fn(payload); e != nil {
+			return off, e
+		}
+		off += walHeaderSize + int64(n)
+	}
+}
+
+// startWriteLog 打开写日志, 出错时不记录日志, 错误可以通过 WriteLogError 获取
+func (c *Cache[K, V]) startWriteLog() {
+	if e := c.openWriteLog(); e != nil {
+		c.wal = &writeLog{err: e}
+	}
+}
+
+// openWriteLog 加载快照并重放日志, 然后打开日志准备追加
+func (c *Cache[K, V]) openWriteLog() error {
+	snapshot := c.walPath + `.snapshot`
+	f, err := os.Open(snapshot)
+	switch {
+	case err == nil:
+		err = c.restore(bufio.NewReader(f), c.clock.Now())
+		f.Close()
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	f, err = os.OpenFile(c.walPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	off, err := readWriteLog(f, func(payload []byte) error {
+		rec := walRecord[K, V]{}
+		if e := c.codec.NewDecoder(bytes.NewReader(payload)).Decode(&rec); e != nil {
+			return e
+		}
+		return c.replay(rec)
+	})
+	if err == nil {
+		err = f.Truncate(off)
+	}
+	if err == nil {
+		_, err = f.Seek(off, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	c.wal = &writeLog{f: f, size: off, maxSize: c.walMaxSize, codec: c.codec}
+	c.wal.compact = func() error {
+		return writeFileAtomic(snapshot, c.Snapshot)
+	}
+	return nil
+}
+
+// replay 执行日志中的一条记录
+func (c *Cache[K, V]) replay(rec walRecord[K, V]) error {
+	deadline := time.Unix(0, rec.Deadline)
+	switch rec.Op {
+	case walSet:
+		if deadline.After(c.clock.Now()) {
+			c.SetWithDeadline(rec.Key, rec.Value, deadline)
+		} else {
+			c.Del(rec.Key)
+		}
+	case walDel:
+		c.Del(rec.Key)
+	case walExpire:
+		if deadline.After(c.clock.Now()) {
+			c.retime(rec.Key, deadline)
+		} else {
+			c.Del(rec.Key)
+		}
+	case walClear:
+		c.Clear()
+	default:
+		return fmt.Errorf(`unknown write log op %v`, rec.Op)
+	}
+	return nil
+}
+
+// WriteLogError 返回最近一次打开或写日志的错误
+func (c *Cache[K, V]) WriteLogError() error {
+	if c.wal == nil {
+		return nil
+	}
+	c.wal.mu.Lock()
+	defer c.wal.mu.Unlock()
+	return c.wal.err
+}
+
+// logSet 记录写入
+func (c *Cache[K, V]) logSet(k K, v V, wp *wrap) {
+	if c.wal == nil {
+		return
+	}
+	deadline := wp.timeout
+	if !wp.hard.IsZero() {
+		deadline = wp.hard
+	}
+	c.wal.append(walRecord[K, V]{Op: walSet, Key: k, Value: v, Deadline: deadline.UnixNano()})
+}
+
+// logDel 记录删除
+func (c *Cache[K, V]) logDel(k K) {
+	if c.wal == nil {
+		return
+	}
+	c.wal.append(walRecord[K, V]{Op: walDel, Key: k})
+}
+
+// logEvicted 把被淘汰的元素记录为删除
+func (c *Cache[K, V]) logEvicted(evicted []removed[K]) {
+	for _, r := range evicted {
+		c.logDel(r.k)
+	}
+}
+
+// logExpire 记录超时时间的修改
+func (c *Cache[K, V]) logExpire(k K, deadline time.Time) {
+	if c.wal == nil {
+		return
+	}
+	c.wal.append(walRecord[K, V]{Op: walExpire, Key: k, Deadline: deadline.UnixNano()})
+}
+
+// logClear 记录清空
+func (c *Cache[K, V]) logClear() {
+	if c.wal == nil {
+		return
+	}
+	c.wal.append(walRecord[K, V]{Op: walClear})
+}
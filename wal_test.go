@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.wal`)
+	c := NewCache(WithWriteLog[string, int](path, 0))
+	require.NoError(t, c.WriteLogError())
+	c.Set(`a`, 1)
+	c.Set(`b`, 2)
+	c.Set(`c`, 3)
+	c.Del(`b`)
+	c.Update(`c`, func(old int) int { return old * 10 })
+	c.Expire(`a`, time.Hour)
+	c.SetWithTTL(`d`, 4, time.Millisecond)
+	require.NoError(t, c.WriteLogError())
+	c.wal.close()
+	time.Sleep(time.Millisecond * 5)
+
+	r := NewCache(WithWriteLog[string, int](path, 0))
+	require.NoError(t, r.WriteLogError())
+	ks := r.ListKey()
+	sort.Strings(ks)
+	require.Equal(t, []string{`a`, `c`}, ks)
+	v, _ := r.Get(`c`)
+	require.Equal(t, 30, v)
+	ttl, _ := r.TTL(`a`)
+	require.LessOrEqual(t, ttl, time.Hour)
+
+	r.Clear()
+	r.wal.close()
+	r = NewCache(WithWriteLog[string, int](path, 0))
+	require.True(t, r.IsEmpty())
+}
+
+func TestWriteLogTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.wal`)
+	c := NewCache(WithWriteLog[string, int](path, 0))
+	c.Set(`a`, 1)
+	c.wal.close()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	f.Write([]byte{0, 0, 0, 20, 1, 2, 3})
+	f.Close()
+
+	r := NewCache(WithWriteLog[string, int](path, 0))
+	require.NoError(t, r.WriteLogError())
+	require.True(t, r.Has(`a`))
+	r.Set(`b`, 2)
+	r.wal.close()
+
+	r = NewCache(WithWriteLog[string, int](path, 0))
+	require.True(t, r.Has(`a`, `b`))
+	info2, _ := os.Stat(path)
+	require.Greater(t, info2.Size(), info.Size())
+}
+
+func TestWriteLogCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.wal`)
+	c := NewCache(WithWriteLog[int, int](path, 1024), WithCodec[int, int](JSONCodec))
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	require.NoError(t, c.WriteLogError())
+	c.wal.close()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.LessOrEqual(t, info.Size(), int64(1024))
+	_, err = os.Stat(path + `.snapshot`)
+	require.NoError(t, err)
+
+	r := NewCache(WithWriteLog[int, int](path, 1024), WithCodec[int, int](JSONCodec))
+	require.Equal(t, 100, r.Len())
+	v, _ := r.Get(99)
+	require.Equal(t, 99, v)
+}
+
+func TestWriteLogOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.wal`)
+	c := NewCache(WithWriteLog[string, int](path, 0))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				c.Set(`k`, i*1000+j)
+				if j%7 == 0 {
+					c.Del(`k`)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	want, wantOK := c.Get(`k`)
+	c.wal.close()
+
+	r := NewCache(WithWriteLog[string, int](path, 0))
+	got, ok := r.Get(`k`)
+	require.Equal(t, wantOK, ok)
+	require.Equal(t, want, got)
+}
+
+func TestWriteLogWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `cache.wal`)
+	c := NewCache(WithWriteLog[string, int](path, 0))
+	c.Set(`a`, 1)
+	c.wal.f.Close()
+	c.Set(`b`, 2)
+	require.Error(t, c.WriteLogError())
+	c.Set(`c`, 3)
+	require.Error(t, c.WriteLogError())
+	require.NoError(t, c.wal.close())
+
+	r := NewCache(WithWriteLog[string, int](path, 0))
+	require.NoError(t, r.WriteLogError())
+	require.Equal(t, []string{`a`}, r.ListKey())
+}
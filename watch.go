@@ -0,0 +1,228 @@
+// Package cache
+/*=============================================================================
+#       Author: peng.wei
+#        Email: weapons97@gmail.com
+#      Version: 0.0.1
+#   LastChange: 20211214
+#      History:
+=============================================================================*/
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrSlowConsumer watcher 消费太慢被断开时收到的错误
+var ErrSlowConsumer = errors.New(`watcher is too slow`)
+
+// EventType 是 Watch 事件的类型
+type EventType int
+
+const (
+	// EventAdded 新增元素
+	EventAdded EventType = iota
+	// EventUpdated 更新元素
+	EventUpdated
+	// EventDeleted 删除元素
+	EventDeleted
+	// EventExpired 元素超时
+	EventExpired
+	// EventError watcher 出错, 之后channel 会被关闭
+	EventError
+)
+
+// String 返回EventType 的名称
+func (et EventType) String() string {
+	switch et {
+	case EventAdded:
+		return `Added`
+	case EventUpdated:
+		return `Updated`
+	case EventDeleted:
+		return `Deleted`
+	case EventExpired:
+		return `Expired`
+	case EventError:
+		return `Error`
+	}
+	return `Unknown`
+}
+
+// Event 是 Watch 收到的事件, Old 只在 EventUpdated 时有值, Err 只在 EventError 时有值
+type Event[T Indexed] struct {
+	Type   EventType
+	Object T
+	Old    T
+	Err    error
+}
+
+// SlowConsumerPolicy 是watcher 缓冲满时的处理方式
+type SlowConsumerPolicy int
+
+const (
+	// SlowConsumerBlock 阻塞写入方直到watcher 有空间或者ctx 结束
+	SlowConsumerBlock SlowConsumerPolicy = iota
+	// SlowConsumerDrop 丢弃事件
+	SlowConsumerDrop
+	// SlowConsumerDisconnect 发送 ErrSlowConsumer 后断开watcher
+	SlowConsumerDisconnect
+)
+
+// WatchOption 是 Watch 的选项
+type WatchOption func(*watchConfig)
+
+// watchConfig Watch 的配置
+type watchConfig struct {
+	replay bool
+	buffer int
+	policy SlowConsumerPolicy
+}
+
+// defaultWatchBuffer watcher 默认的缓冲大小
+const defaultWatchBuffer = 64
+
+// WithReplay watcher 开始时先收到当前所有元素的 EventAdded 事件
+func WithReplay() WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.replay = true
+	}
+}
+
+// WithWatchBuffer 设置watcher 的缓冲大小
+func WithWatchBuffer(n int) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.buffer = n
+	}
+}
+
+// WithSlowConsumerPolicy 设置watcher 缓冲满时的处理方式, 默认 SlowConsumerBlock
+func WithSlowConsumerPolicy(p SlowConsumerPolicy) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.policy = p
+	}
+}
+
+// watcher 是一个 Watch 的订阅者
+type watcher[T Indexed] struct {
+	ctx    context.Context
+	policy SlowConsumerPolicy
+	mu     sync.Mutex // 保护 queue 的发送和关闭
+	queue  chan Event[T]
+	closed bool
+	err    error
+}
+
+// push 按policy 把事件放进缓冲
+func (w *watcher[T]) push(e Event[T]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.queue <- e:
+		return
+	default:
+	}
+	switch w.policy {
+	case SlowConsumerBlock:
+		select {
+		case w.queue <- e:
+		case <-w.ctx.Done():
+		}
+	case SlowConsumerDisconnect:
+		w.err = ErrSlowConsumer
+		w.closeLocked()
+	}
+}
+
+func (w *watcher[T]) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closeLocked()
+}
+
+func (w *watcher[T]) closeLocked() {
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+}
+
+// Watch 订阅Indexer 的变化, ctx 结束或者watcher 被断开时channel 会被关闭
+func (ix *Indexer[T]) Watch(ctx context.Context, opts ...WatchOption) <-chan Event[T] {
+	cfg := watchConfig{buffer: defaultWatchBuffer}
+	for i := range opts {
+		opts[i](&cfg)
+	}
+	w := &watcher[T]{
+		ctx:    ctx,
+		policy: cfg.policy,
+		queue:  make(chan Event[T], cfg.buffer),
+	}
+	var replay []T
+	ix.watchersMu.Lock()
+	if cfg.replay {
+		replay = ix.ListValue()
+	}
+	if ix.watchers == nil {
+		ix.watchers = make(map[*watcher[T]]struct{})
+	}
+	ix.watchers[w] = struct{}{}
+	ix.watchersMu.Unlock()
+
+	out := make(chan Event[T])
+	send := func(e Event[T]) bool {
+		select {
+		case out <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(out)
+		defer ix.unwatch(w)
+		for i := range replay {
+			if !send(Event[T]{Type: EventAdded, Object: replay[i]}) {
+				return
+			}
+		}
+		for {
+			select {
+			case e, ok := <-w.queue:
+				if !ok {
+					if w.err != nil {
+						send(Event[T]{Type: EventError, Err: w.err})
+					}
+					return
+				}
+				if !send(e) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// unwatch 删除watcher
+func (ix *Indexer[T]) unwatch(w *watcher[T]) {
+	ix.watchersMu.Lock()
+	delete(ix.watchers, w)
+	ix.watchersMu.Unlock()
+	w.close()
+}
+
+// emitWatch 把事件发给所有watcher
+func (ix *Indexer[T]) emitWatch(e Event[T]) {
+	ix.watchersMu.RLock()
+	defer ix.watchersMu.RUnlock()
+	for w := range ix.watchers {
+		w.push(e)
+	}
+}
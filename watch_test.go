@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func recvEvent[T Indexed](t *testing.T, ch <-chan Event[T]) Event[T] {
+	select {
+	case e, ok := <-ch:
+		require.True(t, ok)
+		return e
+	case <-time.After(time.Second):
+		t.Fatal(`no event`)
+	}
+	return Event[T]{}
+}
+
+func TestIndexerWatch(t *testing.T) {
+	ix := NewIndexer[*Person]()
+	ix.Set(p1)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := ix.Watch(ctx, WithReplay())
+	e := recvEvent(t, ch)
+	require.Equal(t, EventAdded, e.Type)
+	require.Equal(t, p1, e.Object)
+
+	ix.Set(p2)
+	e = recvEvent(t, ch)
+	require.Equal(t, EventAdded, e.Type)
+	require.Equal(t, p2, e.Object)
+
+	ix.Set(p2)
+	e = recvEvent(t, ch)
+	require.Equal(t, EventUpdated, e.Type)
+	require.Equal(t, p2, e.Old)
+
+	ix.Del(p1.ID())
+	e = recvEvent(t, ch)
+	require.Equal(t, EventDeleted, e.Type)
+	require.Equal(t, p1, e.Object)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestIndexerWatchDelMissing(t *testing.T) {
+	ix := NewIndexer[*Person]()
+	ch := ix.Watch(context.Background())
+	ix.Remove(p1)
+	ix.Del(p2.ID())
+	ix.Set(p3)
+	ix.Remove(p3)
+	ix.Remove(p3)
+	require.Equal(t, EventAdded, recvEvent(t, ch).Type)
+	require.Equal(t, EventDeleted, recvEvent(t, ch).Type)
+	select {
+	case e := <-ch:
+		t.Fatalf(`unexpected event %v`, e.Type)
+	case <-time.After(time.Millisecond * 20):
+	}
+}
+
+func TestIndexerWatchExpired(t *testing.T) {
+	ix := NewIndexer[*Person](WithTTL[string, *Person](time.Millisecond * 20))
+	ch := ix.Watch(context.Background())
+	ix.Set(p1)
+	require.Equal(t, EventAdded, recvEvent(t, ch).Type)
+	time.Sleep(time.Millisecond * 30)
+	ix.main.Clean()
+	e := recvEvent(t, ch)
+	require.Equal(t, EventExpired, e.Type)
+	require.Equal(t, p1, e.Object)
+	require.Empty(t, ix.Search(IndexByLastName, p1.lastName).InvokeAll())
+}
+
+func TestIndexerWatchSlowConsumer(t *testing.T) {
+	ix := NewIndexer[*Person]()
+	drop := ix.Watch(context.Background(), WithWatchBuffer(1), WithSlowConsumerPolicy(SlowConsumerDrop))
+	disconnect := ix.Watch(context.Background(), WithWatchBuffer(1), WithSlowConsumerPolicy(SlowConsumerDisconnect))
+	for _, p := range []*Person{p1, p2, p3, p4} {
+		ix.Set(p)
+	}
+	time.Sleep(time.Millisecond * 20)
+	require.Equal(t, EventAdded, recvEvent(t, drop).Type)
+
+	var last Event[*Person]
+	for e := range disconnect {
+		last = e
+	}
+	require.Equal(t, EventError, last.Type)
+	require.ErrorIs(t, last.Err, ErrSlowConsumer)
+}